@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// personalAccessTokenPrefix tells personal access tokens apart from JWTs
+const personalAccessTokenPrefix = "gsp_"
+
+const (
+	scopePostsRead  = "posts:read"
+	scopePostsWrite = "posts:write"
+	scopeUsersRead  = "users:read"
+	scopeUsersWrite = "users:write"
+	scopeFeedRead   = "feed:read"
+)
+
+type CreateAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write users:read users:write feed:read"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,gte=1,lte=365"`
+}
+
+// CreatedAccessToken is the only response containing the token itself.
+type CreatedAccessToken struct {
+	store.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// getAccessTokensHandler godoc
+//
+//	@Summary		Lists personal access tokens
+//	@Description	Lists the personal access tokens of the current user, the tokens themselves are never returned again
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.PersonalAccessToken
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *applicaion) getAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tokens, err := app.store.AccessTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createAccessTokenHandler godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a token for scripts limited to the given scopes, it is only shown in this response
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAccessTokenPayload	true	"Token name, scopes and expiry"
+//	@Success		201		{object}	CreatedAccessToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *applicaion) createAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAccessTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	random, err := generateOpaqueToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	token := personalAccessTokenPrefix + random
+
+	pat := store.PersonalAccessToken{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Scopes: payload.Scopes,
+	}
+	if payload.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, payload.ExpiresInDays)
+		pat.Expiry = &expiry
+	}
+
+	if err := app.store.AccessTokens.Create(r.Context(), token, &pat); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, CreatedAccessToken{PersonalAccessToken: pat, Token: token}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteAccessTokenHandler godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Tags			users
+//	@Produce		json
+//	@Param			tokenID	path		int		true	"Token ID"
+//	@Success		204		{string}	string	"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *applicaion) deleteAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.AccessTokens.Delete(r.Context(), user.ID, tokenID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type accessTokenKey string
+
+const accessTokenCtx accessTokenKey = "accessToken"
+
+// getAccessTokenFromCtx returns nil unless the request was authenticated with
+// a personal access token.
+func getAccessTokenFromCtx(r *http.Request) *store.PersonalAccessToken {
+	pat, _ := r.Context().Value(accessTokenCtx).(*store.PersonalAccessToken)
+	return pat
+}
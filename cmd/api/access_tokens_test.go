@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryAccessTokenStore finds personal access tokens by their plain value.
+type memoryAccessTokenStore struct {
+	*store.PersonalAccessTokenStore
+	tokens map[string]store.PersonalAccessToken
+}
+
+func (s *memoryAccessTokenStore) GetByToken(_ context.Context, token string) (*store.PersonalAccessToken, error) {
+	pat, ok := s.tokens[token]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &pat, nil
+}
+
+func newAccessTokenTestRouter(t *testing.T, tokens map[string]store.PersonalAccessToken) http.Handler {
+	t.Helper()
+
+	app := &applicaion{
+		store: store.Storage{
+			Users: &memoryUserStore{users: map[int64]*store.User{
+				1: {ID: 1, Username: "alice", IsActive: true},
+			}},
+			AccessTokens: &memoryAccessTokenStore{tokens: tokens},
+		},
+		logger: zap.NewNop().Sugar(),
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	r := chi.NewRouter()
+	r.Use(app.AuthTokenMiddleware)
+	r.With(app.requireScope(scopePostsRead)).Get("/posts", ok)
+	r.With(app.requireScope(scopePostsWrite)).Post("/posts", ok)
+	r.With(app.requireSessionMiddleware).Delete("/users/me", ok)
+
+	return r
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	router := newAccessTokenTestRouter(t, map[string]store.PersonalAccessToken{
+		"gsp_reader": {ID: 1, UserID: 1, Name: "reader", Scopes: []string{scopePostsRead}},
+		"gsp_orphan": {ID: 2, UserID: 2, Name: "orphan", Scopes: []string{scopePostsRead}},
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"granted scope", http.MethodGet, "/posts", "gsp_reader", http.StatusOK},
+		{"missing scope", http.MethodPost, "/posts", "gsp_reader", http.StatusForbidden},
+		{"route needing a session", http.MethodDelete, "/users/me", "gsp_reader", http.StatusForbidden},
+		{"unknown token", http.MethodGet, "/posts", "gsp_unknown", http.StatusUnauthorized},
+		{"token of a missing user", http.MethodGet, "/posts", "gsp_orphan", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rr.Code, tt.status, rr.Body)
+			}
+		})
+	}
+}
+
+func TestInsufficientScopeNamesTheScope(t *testing.T) {
+	router := newAccessTokenTestRouter(t, map[string]store.PersonalAccessToken{
+		"gsp_reader": {ID: 1, UserID: 1, Name: "reader", Scopes: []string{scopePostsRead}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+	req.Header.Set("Authorization", "Bearer gsp_reader")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("WWW-Authenticate"); !strings.Contains(got, `scope="`+scopePostsWrite+`"`) {
+		t.Fatalf("WWW-Authenticate = %q, want the %s scope", got, scopePostsWrite)
+	}
+}
+
+func TestPersonalAccessTokenOfASanctionedUser(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+
+	app := &applicaion{
+		store: store.Storage{
+			Users: &memoryUserStore{users: map[int64]*store.User{
+				1: {ID: 1, Username: "alice", IsActive: true, SuspendedUntil: &until},
+			}},
+			AccessTokens: &memoryAccessTokenStore{tokens: map[string]store.PersonalAccessToken{
+				"gsp_reader": {ID: 1, UserID: 1, Name: "reader", Scopes: []string{scopePostsRead}},
+			}},
+		},
+		logger: zap.NewNop().Sugar(),
+	}
+
+	handler := app.AuthTokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("the request of a suspended user went through")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	req.Header.Set("Authorization", "Bearer gsp_reader")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireScopeLeavesLoginTokensAlone(t *testing.T) {
+	app := &applicaion{logger: zap.NewNop().Sugar()}
+
+	called := false
+	handler := app.requireScope(scopePostsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// no personal access token in the context, the request came with a login token
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/posts", nil))
+
+	if !called {
+		t.Fatal("a request without a personal access token was rejected")
+	}
+}
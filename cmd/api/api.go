@@ -15,7 +15,12 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/mayankpatidar275/go-social/docs" // This is required to generate swagger docs
 	"github.com/mayankpatidar275/go-social/internal/auth"
+	"github.com/mayankpatidar275/go-social/internal/auth/oidc"
+	"github.com/mayankpatidar275/go-social/internal/cursor"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
 	"github.com/mayankpatidar275/go-social/internal/mailer"
+	"github.com/mayankpatidar275/go-social/internal/password"
+	"github.com/mayankpatidar275/go-social/internal/ranking"
 	"github.com/mayankpatidar275/go-social/internal/ratelimiter"
 	"github.com/mayankpatidar275/go-social/internal/store"
 	"github.com/mayankpatidar275/go-social/internal/store/cache"
@@ -33,6 +38,11 @@ type applicaion struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	lockout       *lockout.Tracker
+	cursors       *cursor.Signer
+	ranker        *ranking.Ranker
+
+	identityProviders map[string]oidc.IdentityProvider
 }
 
 type config struct {
@@ -45,6 +55,39 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	lockout     lockout.Config
+	janitor     janitorConfig
+	export      exportConfig
+	timeline    timelineConfig
+
+	passwordPolicy password.Policy
+	// cursorSecret signs the pagination cursors
+	cursorSecret string
+	// publicCacheTTL is how long the responses of the public lists are cached
+	publicCacheTTL time.Duration
+	// trendingWindow is the sliding window the trending tags are computed over
+	trendingWindow time.Duration
+	// usernameRedirectPeriod is how long old usernames of renamed and purged
+	// users stay held, redirecting to the renamed user
+	usernameRedirectPeriod time.Duration
+}
+
+type janitorConfig struct {
+	interval time.Duration
+	// unactivatedGracePeriod is how long after their invitation expired
+	// unactivated users keep their username and email
+	unactivatedGracePeriod time.Duration
+	// restoreWindow is how long deleted accounts can be restored before they are purged
+	restoreWindow time.Duration
+}
+
+type exportConfig struct {
+	dir string
+	// downloadURL is the public URL of the download endpoint, the token is appended
+	downloadURL string
+	exp         time.Duration
+	// cooldown is the minimum time between two new exports of the same user
+	cooldown time.Duration
 }
 
 type redisConfig struct {
@@ -56,12 +99,25 @@ type redisConfig struct {
 type authConfig struct {
 	basic basicConfig
 	token tokenConfig
+	mfa   mfaConfig
+	oidc  []oidc.Config
+}
+
+type mfaConfig struct {
+	issuer             string
+	challengeExp       time.Duration
+	enforced           bool
+	requiredAboveLevel int
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
+	alg        string
+	keysDir    string
+	signingKID string
 }
 
 type basicConfig struct {
@@ -72,6 +128,12 @@ type mailConfig struct {
 	sendGrid  sendGridConfig
 	fromEmail string
 	exp       time.Duration
+	resetExp  time.Duration
+	// resendCooldown is the minimum time between two invitations to the same user
+	resendCooldown time.Duration
+	// resetCooldown is the minimum time between two password resets of the same user
+	resetCooldown  time.Duration
+	emailChangeExp time.Duration
 }
 
 type sendGridConfig struct {
@@ -89,7 +151,7 @@ func (app *applicaion) mount() http.Handler {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -110,6 +172,8 @@ func (app *applicaion) mount() http.Handler {
 
 	// Grouping the endpoints in logical way and using middleware(easy with this library)
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 		// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
 		r.Get("/health", app.healthCheckHandler)
@@ -118,38 +182,138 @@ func (app *applicaion) mount() http.Handler {
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
 		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
-			r.Post("/", app.createPostHandler)
-
-			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
+			r.With(app.publicCacheMiddleware).Get("/", app.getPublicPostsHandler)
 
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.With(app.requireScope(scopePostsWrite)).Post("/", app.createPostHandler)
+
+				r.Route("/{postID}", func(r chi.Router) {
+					r.Use(app.postsContextMiddleware)
+					r.With(app.requireScope(scopePostsRead)).Get("/", app.getPostHandler)
+					r.With(app.requireScope(scopePostsRead)).Get("/comments", app.getCommentsHandler)
+					r.With(app.requireScope(scopePostsWrite)).Put("/like", app.likePostHandler)
+					r.With(app.requireScope(scopePostsWrite)).Delete("/like", app.unlikePostHandler)
+
+					r.With(app.requireScope(scopePostsWrite)).Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+					r.With(app.requireScope(scopePostsWrite)).Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				})
 			})
 		})
 
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.publicCacheMiddleware)
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
+		r.Get("/exports/{token}", app.downloadExportHandler)
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.With(app.requireScope(scopeUsersRead)).Get("/", app.getCurrentUserHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Patch("/", app.updateCurrentUserHandler)
+					r.With(app.requireSessionMiddleware).Delete("/", app.deleteCurrentUserHandler)
+				})
+
+				r.With(app.AuthTokenMiddleware, app.requireSessionMiddleware).Post("/email", app.changeEmailHandler)
+				r.With(app.AuthTokenMiddleware, app.requireSessionMiddleware).Put("/password", app.changePasswordHandler)
+				r.With(app.AuthTokenMiddleware, app.requireSessionMiddleware).Post("/export", app.requestExportHandler)
+
+				r.Route("/mfa", func(r chi.Router) {
+					r.Use(app.MFAEnrollmentAuthMiddleware)
+					r.Post("/totp", app.enrollTOTPHandler)
+					r.Post("/totp/confirm", app.confirmTOTPHandler)
+					r.Delete("/totp", app.disableTOTPHandler)
+					r.Post("/recovery-codes", app.regenerateRecoveryCodesHandler)
+				})
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.With(app.requireScope(scopeUsersRead)).Get("/", app.getFollowRequestsHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}/approve", app.approveFollowRequestHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}/reject", app.rejectFollowRequestHandler)
+				})
+
+				r.Route("/blocks", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.With(app.requireScope(scopeUsersRead)).Get("/", app.getBlocksHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}", app.blockUserHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Delete("/{userID}", app.unblockUserHandler)
+				})
+
+				r.Route("/mutes", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.With(app.requireScope(scopeUsersRead)).Get("/", app.getMutesHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Put("/{userID}", app.muteUserHandler)
+					r.With(app.requireScope(scopeUsersWrite)).Delete("/{userID}", app.unmuteUserHandler)
+				})
+
+				r.Route("/sessions", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware, app.requireSessionMiddleware)
+					r.Get("/", app.getSessionsHandler)
+					r.Delete("/{sessionID}", app.deleteSessionHandler)
+				})
+
+				r.Route("/tokens", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware, app.requireSessionMiddleware)
+					r.Get("/", app.getAccessTokensHandler)
+					r.Post("/", app.createAccessTokenHandler)
+					r.Delete("/{tokenID}", app.deleteAccessTokenHandler)
+				})
+			})
+
+			r.With(app.AuthTokenMiddleware, app.requireScope(scopeUsersRead)).Get("/by-username/{username}", app.getUserByUsernameHandler)
+
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/", app.getUserHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/followers", app.getFollowersHandler)
+				r.With(app.requireScope(scopeUsersRead)).Get("/following", app.getFollowingHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/follow", app.followUserHandler)
+				r.With(app.requireScope(scopeUsersWrite)).Put("/unfollow", app.unfollowUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.requireScope(scopeFeedRead)).Get("/feed", app.getUserFeedHandler)
 			})
 		})
 
+		// Moderation routes, the ban is for admins only
+		r.Route("/admin/users/{userID}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware, app.requireSessionMiddleware, app.requireRoleMiddleware("moderator"))
+			r.Get("/moderation", app.getModerationHistoryHandler)
+			r.Post("/suspend", app.suspendUserHandler)
+			r.With(app.requireRoleMiddleware("admin")).Post("/ban", app.banUserHandler)
+			r.Post("/lift", app.liftSanctionHandler)
+		})
+
 		// Public routes
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
+			r.Post("/invitation/resend", app.resendInvitationHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+
+			r.Post("/token/mfa", app.verifyMFAHandler)
+			r.Get("/oidc/{provider}", app.oidcAuthorizeHandler)
+			r.Post("/oidc/{provider}/callback", app.oidcCallbackHandler)
+			r.Post("/appeal", app.appealHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.MFAEnrollmentAuthMiddleware)
+				r.Post("/logout", app.logoutHandler)
+				r.Post("/logout-all", app.logoutAllHandler)
+			})
 
 		})
 	})
@@ -187,6 +351,10 @@ func (app *applicaion) run(mux http.Handler) error {
 		shutdown <- srv.Shutdown(ctx)
 	}()
 
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	go app.runJanitor(janitorCtx)
+
 	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
 
 	err := srv.ListenAndServe()
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
 	"github.com/mayankpatidar275/go-social/internal/mailer"
 	"github.com/mayankpatidar275/go-social/internal/store"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 type UserWithToken struct {
@@ -48,6 +54,15 @@ func (app *applicaion) registerUserHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if isReservedUsername(payload.Username) {
+		app.badRequestResponse(w, r, errReservedUsername)
+		return
+	}
+
+	if !app.checkPasswordPolicy(w, r, payload.Password, payload.Username, payload.Email) {
+		return
+	}
+
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
@@ -88,19 +103,8 @@ func (app *applicaion) registerUserHandler(w http.ResponseWriter, r *http.Reques
 		Token: plainToken,
 	}
 
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
-
-	isProdEnv := app.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
-
 	// send mail
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	status, err := app.sendInvitationEmail(user, plainToken)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err)
 
@@ -134,9 +138,13 @@ type CreateUserTokenPayload struct {
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	TokenPair				"Token"
+//	@Success		200		{object}	MFAChallenge			"Two-factor authentication required"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Account suspended or banned"
+//	@Failure		423		{object}	error	"Account locked"
+//	@Failure		429		{object}	error	"Too many failed attempts"
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *applicaion) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -151,10 +159,189 @@ func (app *applicaion) createTokenHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	user, ok := app.checkCredentials(w, r, payload.Email, payload.Password)
+	if !ok {
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// dummyPasswordHash is compared to the password of the logins of unknown
+// emails, it has the cost of the hashes of the users.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not the password of anyone"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// checkCredentials returns the user owning the email and password, with the
+// brute force protection of the login. Otherwise it answers the request.
+func (app *applicaion) checkCredentials(w http.ResponseWriter, r *http.Request, email, password string) (*store.User, bool) {
+	ctx := r.Context()
+
+	accountKey := lockout.AccountKey(email)
+	ipKey := lockout.IPKey(r.RemoteAddr)
+
+	retryAfter, err := app.lockout.Check(ctx, ipKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	if retryAfter > 0 {
+		app.tooManyLoginAttemptsResponse(w, r, retryAfter)
+		return nil, false
+	}
+
+	retryAfter, err = app.lockout.Check(ctx, accountKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	if retryAfter > 0 {
+		app.accountLockedResponse(w, r, retryAfter)
+		return nil, false
+	}
+
+	user, err := app.store.Users.GetByEmailIncludingDeleted(ctx, email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			// Note: takes as long as a wrong password, the timing would tell
+			// which emails have an account otherwise
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+			app.failedLoginResponse(w, r, accountKey, ipKey, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if err := user.Password.Compare(password); err != nil {
+		app.failedLoginResponse(w, r, accountKey, ipKey, err)
+		return nil, false
+	}
+
+	if err := app.lockout.Reset(ctx, accountKey); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// completeLogin answers a successful first factor (password or identity provider)
+// with tokens, or with a challenge when two-factor authentication is enabled.
+func (app *applicaion) completeLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	ctx := r.Context()
+
+	if user.Sanctioned() {
+		app.accountSuspendedResponse(w, r, user)
+		return
+	}
+
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if mfa.Enabled() {
+		challenge, err := app.generateMFAChallenge(user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !app.restoreDeletedAccount(w, r, user) {
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user, false)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// restoreDeletedAccount brings a deleted account back, logging in within the
+// restoration window does. It is only called once the login succeeded, right
+// before issuing the tokens. It answers the request when it returns false.
+func (app *applicaion) restoreDeletedAccount(w http.ResponseWriter, r *http.Request, user *store.User) bool {
+	if user.DeletedAt == nil {
+		return true
+	}
+
+	if err := app.store.Users.Restore(r.Context(), user.ID, app.config.janitor.restoreWindow); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("the account was deleted"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return false
+	}
+
+	user.DeletedAt = nil
+	return true
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	TokenPair
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *applicaion) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	newRefreshToken, err := generateOpaqueToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	rt, err := app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, newRefreshToken, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reuse detected, token family revoked and session ended", "remote_addr", r.RemoteAddr)
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -162,22 +349,311 @@ func (app *applicaion) createTokenHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user, err := app.getUser(ctx, rt.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Sanctioned() {
+		app.accountSuspendedResponse(w, r, user)
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(user, rt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := app.newTokenPair(accessToken, newRefreshToken)
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// issueTokens starts a new session, and its refresh token family, for the user. It is called on every fresh login.
+// mfa tells whether the login was completed with a second factor.
+func (app *applicaion) issueTokens(r *http.Request, user *store.User, mfa bool) (*TokenPair, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IP:        clientIP(r),
+	}
+
+	rt := &store.RefreshToken{
+		MFA:    mfa,
+		Expiry: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+
+	if err := app.store.Sessions.Create(r.Context(), session, refreshToken, rt); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(user, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.newTokenPair(accessToken, refreshToken), nil
+}
+
+// generateAccessToken signs a token for the user. The sid claim is the refresh
+// token family the token belongs to, so logging out can revoke both.
+func (app *applicaion) generateAccessToken(user *store.User, rt *store.RefreshToken) (string, error) {
+	amr := []string{"pwd"}
+	if rt.MFA {
+		amr = append(amr, "otp")
+	}
+
 	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
+		"jti":       uuid.New().String(),
+		"sid":       rt.FamilyID,
+		"amr":       amr,
+		"token_use": tokenUseAccess,
+		"sub":       user.ID,
+		"exp":       time.Now().Add(app.config.auth.token.exp).Unix(),
+		"iat":       jwt.NewNumericDate(time.Now()),
+		"nbf":       time.Now().Unix(),
+		"iss":       app.config.auth.token.iss,
+		"aud":       app.config.auth.token.iss,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+func (app *applicaion) newTokenPair(accessToken, refreshToken string) *TokenPair {
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}
+}
+
+// generateOpaqueToken returns a random url safe token, unlike a JWT it carries no information.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// failedLoginResponse counts the failure against both the account and the IP,
+// the account lock is reported as soon as the failure triggers it.
+func (app *applicaion) failedLoginResponse(w http.ResponseWriter, r *http.Request, accountKey, ipKey string, err error) {
+	retryAfter, lockErr := app.recordFailedLogin(r.Context(), accountKey, ipKey)
+	if lockErr != nil {
+		app.internalServerError(w, r, lockErr)
+		return
+	}
+
+	if retryAfter > 0 {
+		app.accountLockedResponse(w, r, retryAfter)
+		return
+	}
+
+	app.unauthorizedErrorResponse(w, r, err)
+}
+
+// recordFailedLogin counts the failure against the account and the IP, it
+// returns how long the account is locked for when the failure locked it.
+func (app *applicaion) recordFailedLogin(ctx context.Context, accountKey, ipKey string) (time.Duration, error) {
+	if _, err := app.lockout.Fail(ctx, ipKey, app.config.lockout.MaxIPAttempts); err != nil {
+		return 0, err
+	}
+
+	return app.lockout.Fail(ctx, accountKey, app.config.lockout.MaxAccountAttempts)
+}
+
+type ResendInvitationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// resendInvitationHandler godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Mails a new activation link to an inactive user, it replaces the earlier links once sent. Always answers 202, even for unknown emails, while throttled or when the mail fails
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendInvitationPayload	true	"Email of the account"
+//	@Success		202		{string}	string					"Invitation sent when the account is pending activation"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/invitation/resend [post]
+func (app *applicaion) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendInvitationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	user, err := app.store.Users.ResendInvitation(r.Context(), payload.Email, hashToken, app.config.mail.exp, app.config.mail.resendCooldown)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound, store.ErrThrottled:
+			// Note: same answer as a sent invitation, it would allow enumerating users otherwise
+			app.logger.Infow("invitation not resent", "error", err.Error())
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	status, err := app.sendInvitationEmail(user, plainToken)
 	if err != nil {
+		// the earlier links still work, the user can ask again after the cooldown
+		app.logger.Errorw("error resending invitation", "user", user.ID, "error", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	// the new link replaces the earlier ones once it is on its way
+	if err := app.store.Users.DeleteOtherInvitations(r.Context(), user.ID, hashToken); err != nil {
+		app.logger.Errorw("error deleting replaced invitations", "user", user.ID, "error", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *applicaion) sendInvitationEmail(user *store.User, plainToken string) (int, error) {
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	return app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the current access token and its refresh tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *applicaion) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	claims := getClaimsFromCtx(r)
+
+	ctx := r.Context()
+
+	if err := app.revokeToken(ctx, user.ID, claims); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if err := app.store.Sessions.Delete(ctx, user.ID, sid); err != nil && err != store.ErrNotFound {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler godoc
+//
+//	@Summary		Logs out everywhere
+//	@Description	Revokes every access, refresh and personal access token issued to the user
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout-all [post]
+func (app *applicaion) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.revokeAllTokens(r.Context(), user.ID); err != nil {
 		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *applicaion) revokeToken(ctx context.Context, userID int64, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return err
 	}
+
+	if err := app.store.RevokedTokens.Revoke(ctx, jti, userID, exp.Time); err != nil {
+		return err
+	}
+
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.RevokedTokens.SetRevoked(ctx, jti, true, time.Until(exp.Time))
+}
+
+func (app *applicaion) revokeAllTokens(ctx context.Context, userID int64) error {
+	validAfter, err := app.store.RevokedTokens.RevokeAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.RevokedTokens.SetValidAfter(ctx, userID, validAfter)
+}
+
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+func getClaimsFromCtx(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
 }
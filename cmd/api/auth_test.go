@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDummyPasswordHashCostsLikeAUserHash(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Fatalf("dummy hash cost = %d, want %d like the user passwords", cost, bcrypt.DefaultCost)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte("correct horse battery staple")); err == nil {
+		t.Fatal("the dummy hash matches a password")
+	}
+}
+
+// memoryInvitationUserStore keeps the invitation tokens of a single inactive
+// user of memoryUserStore.
+type memoryInvitationUserStore struct {
+	*memoryUserStore
+	tokens []string
+}
+
+func (s *memoryInvitationUserStore) ResendInvitation(_ context.Context, email, token string, _, _ time.Duration) (*store.User, error) {
+	user := s.users[1]
+	if user.Email != email {
+		return nil, store.ErrNotFound
+	}
+	s.tokens = append(s.tokens, token)
+	return user, nil
+}
+
+func (s *memoryInvitationUserStore) DeleteOtherInvitations(_ context.Context, _ int64, token string) error {
+	s.tokens = slices.DeleteFunc(s.tokens, func(t string) bool { return t != token })
+	return nil
+}
+
+func TestResendInvitation(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		mailErr    error
+		wantTokens int
+		// the pending invitation is only dropped once the new one is sent
+		wantPending bool
+	}{
+		{name: "sent", email: "alice@example.com", wantTokens: 1},
+		{name: "mail fails", email: "alice@example.com", mailErr: errors.New("mail provider down"), wantTokens: 2, wantPending: true},
+		{name: "unknown email", email: "bob@example.com", wantTokens: 1, wantPending: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &memoryInvitationUserStore{
+				memoryUserStore: &memoryUserStore{users: map[int64]*store.User{
+					1: {ID: 1, Username: "alice", Email: "alice@example.com"},
+				}},
+				tokens: []string{"pending"},
+			}
+			mailer := &recordingMailer{sent: make(chan string, 1), err: tt.mailErr}
+
+			app := &applicaion{
+				store:  store.Storage{Users: users},
+				logger: zap.NewNop().Sugar(),
+				mailer: mailer,
+			}
+
+			body, err := json.Marshal(ResendInvitationPayload{Email: tt.email})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			app.resendInvitationHandler(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusAccepted)
+			}
+			if len(users.tokens) != tt.wantTokens {
+				t.Fatalf("%d invitations left, want %d", len(users.tokens), tt.wantTokens)
+			}
+			if pending := slices.Contains(users.tokens, "pending"); pending != tt.wantPending {
+				t.Fatalf("pending invitation kept = %v, want %v", pending, tt.wantPending)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var errSelfRelation = errors.New("you can't block or mute yourself")
+
+// getBlocksHandler godoc
+//
+//	@Summary		Lists blocked users
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.RelatedUser
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks [get]
+func (app *applicaion) getBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.Blocks.GetBlocked)
+}
+
+// blockUserHandler godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Removes the follows between the users, and stops them from following each other, commenting on each other's posts and seeing each other's content
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks/{userID} [put]
+func (app *applicaion) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, func(ctx context.Context, userID, otherID int64) error {
+		if err := app.store.Blocks.Block(ctx, userID, otherID); err != nil {
+			return err
+		}
+
+		// blocking removes the follows both ways
+		app.purgeTimeline(ctx, userID, otherID)
+		app.purgeTimeline(ctx, otherID, userID)
+		return nil
+	})
+}
+
+// unblockUserHandler godoc
+//
+//	@Summary		Unblocks a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/blocks/{userID} [delete]
+func (app *applicaion) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.unrelateUser(w, r, app.store.Blocks.Unblock)
+}
+
+// getMutesHandler godoc
+//
+//	@Summary		Lists muted users
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.RelatedUser
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes [get]
+func (app *applicaion) getMutesHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.Blocks.GetMuted)
+}
+
+// muteUserHandler godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Keeps the posts of the user out of the feed, the user is not told
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes/{userID} [put]
+func (app *applicaion) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, func(ctx context.Context, userID, otherID int64) error {
+		if err := app.store.Blocks.Mute(ctx, userID, otherID); err != nil {
+			return err
+		}
+
+		app.purgeTimeline(ctx, userID, otherID)
+		return nil
+	})
+}
+
+// unmuteUserHandler godoc
+//
+//	@Summary		Unmutes a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mutes/{userID} [delete]
+func (app *applicaion) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.unrelateUser(w, r, func(ctx context.Context, userID, otherID int64) error {
+		if err := app.store.Blocks.Unmute(ctx, userID, otherID); err != nil {
+			return err
+		}
+
+		app.restoreTimeline(ctx, userID, otherID)
+		return nil
+	})
+}
+
+func (app *applicaion) listRelatedUsers(w http.ResponseWriter, r *http.Request, list func(context.Context, int64) ([]store.RelatedUser, error)) {
+	user := getUserFromCtx(r)
+
+	users, err := list(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *applicaion) relateUser(w http.ResponseWriter, r *http.Request, relate func(ctx context.Context, userID, otherID int64) error) {
+	otherID, ok := app.readRelatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := relate(ctx, getUserFromCtx(r).ID, otherID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *applicaion) unrelateUser(w http.ResponseWriter, r *http.Request, unrelate func(ctx context.Context, userID, otherID int64) error) {
+	otherID, ok := app.readRelatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := unrelate(r.Context(), getUserFromCtx(r).ID, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *applicaion) readRelatedUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 0, false
+	}
+
+	if otherID == getUserFromCtx(r).ID {
+		app.badRequestResponse(w, r, errSelfRelation)
+		return 0, false
+	}
+
+	return otherID, true
+}
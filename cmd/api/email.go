@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mayankpatidar275/go-social/internal/mailer"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var errIncorrectPassword = errors.New("the current password is incorrect")
+
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// changeEmailHandler godoc
+//
+//	@Summary		Changes the email address
+//	@Description	Sends a confirmation link to the new address and a notice to the current one, the address only changes once confirmed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string				"Confirmation sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [post]
+func (app *applicaion) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	// the cached user has no password hash
+	user, err := app.store.Users.GetByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := user.Password.Compare(payload.Password); err != nil {
+		app.badRequestResponse(w, r, errIncorrectPassword)
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	// hash the token for storage but keep the plain token for email
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err = app.store.Users.CreateEmailChange(ctx, user.ID, payload.Email, hashToken, app.config.mail.emailChangeExp)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username   string
+		ConfirmURL string
+		Expiry     string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		Expiry:     app.config.mail.emailChangeExp.String(),
+	}
+
+	status, err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, payload.Email, vars, !isProdEnv)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+
+	notice := struct {
+		Username string
+		NewEmail string
+	}{
+		Username: user.Username,
+		NewEmail: payload.Email,
+	}
+
+	// the change is already requested, a lost notice should not fail it
+	if _, err := app.mailer.Send(mailer.EmailChangeNoticeTemplate, user.Username, user.Email, notice, !isProdEnv); err != nil {
+		app.logger.Errorw("error sending email change notice", "error", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// confirmEmailHandler godoc
+//
+//	@Summary		Confirms an email change
+//	@Description	Swaps the email of the user with the pending address the token was sent to
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Email change token"
+//	@Success		200		{object}	store.User
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/email/confirm/{token} [put]
+func (app *applicaion) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	hash := sha256.Sum256([]byte(chi.URLParam(r, "token")))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.ConfirmEmailChange(ctx, hashToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
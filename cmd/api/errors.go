@@ -1,7 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/password"
+	"github.com/mayankpatidar275/go-social/internal/store"
 )
 
 // Note: errors are logged by the top most layer, database layer can just return the golang errors
@@ -45,6 +51,45 @@ func (app *applicaion) forbiddenResponse(w http.ResponseWriter, r *http.Request)
 	writeJSONError(w, http.StatusForbidden, "forbidden")
 }
 
+func (app *applicaion) mfaRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("two-factor authentication required", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusForbidden, "two-factor authentication is required for this account")
+}
+
+func (app *applicaion) insufficientScopeResponse(w http.ResponseWriter, r *http.Request, scope string) {
+	app.logger.Warnw("insufficient scope", "method", r.Method, "path", r.URL.Path, "scope", scope)
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+
+	writeJSONError(w, http.StatusForbidden, "the token is missing the "+scope+" scope")
+}
+
+func (app *applicaion) passwordPolicyResponse(w http.ResponseWriter, r *http.Request, err *password.PolicyError) {
+	app.logger.Warnw("password policy", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	type envelope struct {
+		Error      string               `json:"error"`
+		Violations []password.Violation `json:"violations"`
+	}
+
+	writeJSON(w, http.StatusBadRequest, &envelope{Error: "password does not meet the policy", Violations: err.Violations})
+}
+
+func (app *applicaion) accountSuspendedResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	app.logger.Warnw("account suspended", "method", r.Method, "path", r.URL.Path, "user", user.ID)
+
+	message := "the account is banned"
+	if user.BannedAt == nil && user.SuspendedUntil != nil {
+		message = "the account is suspended until " + user.SuspendedUntil.UTC().Format(time.RFC3339)
+	}
+	if user.ModerationReason != "" {
+		message += ": " + user.ModerationReason
+	}
+
+	writeJSONError(w, http.StatusForbidden, message)
+}
+
 func (app *applicaion) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
@@ -52,3 +97,21 @@ func (app *applicaion) rateLimitExceededResponse(w http.ResponseWriter, r *http.
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *applicaion) accountLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("account locked", "method", r.Method, "path", r.URL.Path)
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+
+	writeJSONError(w, http.StatusLocked, fmt.Sprintf("account locked due to too many failed login attempts, retry after %d seconds", seconds))
+}
+
+func (app *applicaion) tooManyLoginAttemptsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("too many login attempts", "method", r.Method, "path", r.URL.Path)
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+
+	writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("too many failed login attempts, retry after %d seconds", seconds))
+}
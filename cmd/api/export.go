@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/export"
+	"github.com/mayankpatidar275/go-social/internal/mailer"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// exportTimeout bounds the background work of one export
+const exportTimeout = time.Minute * 5
+
+// requestExportHandler godoc
+//
+//	@Summary		Requests a data export
+//	@Description	Assembles a ZIP archive of the user's data in the background and emails a download link once it is ready
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.DataExport	"The ready export, its link was mailed already"
+//	@Success		202	{object}	store.DataExport
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		409	{object}	error	"An export is already in progress"
+//	@Failure		429	{object}	error	"The last export is too recent"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *applicaion) requestExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	dataExport := &store.DataExport{
+		UserID: user.ID,
+		Expiry: time.Now().Add(app.config.export.exp),
+	}
+
+	if err := app.store.DataExports.Create(r.Context(), token, dataExport, app.config.export.cooldown); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, fmt.Errorf("an export is already in progress"))
+		case store.ErrThrottled:
+			app.rateLimitExceededResponse(w, r, app.config.export.cooldown.String())
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if dataExport.Status == store.DataExportReady {
+		if err := app.jsonResponse(w, http.StatusOK, dataExport); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the request context ends with the response, the export outlives it
+	go app.buildExport(dataExport, token)
+
+	if err := app.jsonResponse(w, http.StatusAccepted, dataExport); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// downloadExportHandler godoc
+//
+//	@Summary		Downloads a data export
+//	@Description	Downloads the archive the emailed token was issued for, until it expires
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			token	path		string	true	"Download token"
+//	@Success		200		{file}		file
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/exports/{token} [get]
+func (app *applicaion) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	dataExport, err := app.store.DataExports.GetByToken(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	f, err := os.Open(dataExport.FilePath)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="gosocial-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	http.ServeContent(w, r, "", *dataExport.CompletedAt, f)
+}
+
+func (app *applicaion) buildExport(dataExport *store.DataExport, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	user, err := app.writeExport(ctx, dataExport)
+	if err != nil {
+		app.logger.Errorw("error building data export", "export", dataExport.ID, "error", err)
+
+		if err := app.store.DataExports.Fail(ctx, dataExport.ID); err != nil {
+			app.logger.Errorw("error marking data export failed", "export", dataExport.ID, "error", err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username    string
+		DownloadURL string
+		Expiry      string
+	}{
+		Username:    user.Username,
+		DownloadURL: fmt.Sprintf("%s/%s", app.config.export.downloadURL, token),
+		Expiry:      app.config.export.exp.String(),
+	}
+
+	status, err := app.mailer.Send(mailer.DataExportTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending data export email", "export", dataExport.ID, "error", err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+}
+
+// writeExport writes the archive to the export directory and marks the export
+// ready. It returns the exported user.
+func (app *applicaion) writeExport(ctx context.Context, dataExport *store.DataExport) (*store.User, error) {
+	data, err := app.store.DataExports.CollectPersonalData(ctx, dataExport.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(app.config.export.dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(app.config.export.dir, fmt.Sprintf("export-%d.zip", dataExport.ID))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := export.Write(f, data, now); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	// the link is valid for the full period from the moment it is mailed
+	if err := app.store.DataExports.Complete(ctx, dataExport.ID, path, now.Add(app.config.export.exp)); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return data.User, nil
+}
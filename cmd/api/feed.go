@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/mayankpatidar275/go-social/internal/store"
@@ -9,19 +10,24 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the posts of the authenticated user and of the users they follow, newest first. The ranked mode also takes popular posts and orders them by score, the score is explained to admins asking for debug
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
+//	@Param			mode	query		string	false	"chronological (default) or ranked"
+//	@Param			debug	query		bool	false	"Explain the scores of the ranked feed, admins only"
+//	@Param			since	query		string	false	"Oldest creation time included, RFC 3339 or 2006-01-02 15:04:05 in UTC"
+//	@Param			until	query		string	false	"Creation time excluded onwards, RFC 3339 or 2006-01-02 15:04:05 in UTC"
 //	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of the previous response"
+//	@Param			offset	query		int		false	"Offset, deprecated in favour of cursor"
 //	@Param			sort	query		string	false	"Sort"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
@@ -30,6 +36,7 @@ func (app *applicaion) getUserFeedHandler(w http.ResponseWriter, r *http.Request
 		Limit:  20,
 		Offset: 0,
 		Sort:   "desc",
+		Mode:   "chronological",
 	}
 
 	fq, err := fq.Parse(r)
@@ -44,14 +51,54 @@ func (app *applicaion) getUserFeedHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+	user := getUserFromCtx(r)
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(66), fq)
+	if fq.Mode == "ranked" {
+		app.rankedFeed(w, r, user, fq)
+		return
+	}
+
+	// Note: the cursors depend on the sort order, they can't be reused across orders
+	scope := fmt.Sprintf("feed:%d:%s", user.ID, fq.Sort)
+
+	fq.Cursor, fq.Backward, err = app.readCursor(r, scope)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if r.URL.Query().Has("offset") {
+		if fq.Cursor != nil {
+			app.badRequestResponse(w, r, errCursorWithOffset)
+			return
+		}
+		w.Header().Set("Deprecation", "true")
+	}
+
+	if app.canReadTimeline(fq) {
+		feed, info, ok, err := app.readTimeline(ctx, user, fq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if ok {
+			go app.verifyTimeline(user.ID, fq, feed, info.Next)
+
+			if err := app.paginatedResponse(w, r, scope, feed, info); err != nil {
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	feed, info, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.paginatedResponse(w, r, scope, feed, info); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// getFollowRequestsHandler godoc
+//
+//	@Summary		Lists follow requests
+//	@Description	Lists the pending requests to follow the current user, oldest first
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.FollowRequest
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *applicaion) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	requests, err := app.store.Followers.GetRequests(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// approveFollowRequestHandler godoc
+//
+//	@Summary		Approves a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"Requester ID"
+//	@Success		204		{string}	string	"Request approved"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [put]
+func (app *applicaion) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, func(ctx context.Context, userID, requesterID int64) error {
+		if err := app.store.Followers.Approve(ctx, userID, requesterID); err != nil {
+			return err
+		}
+
+		app.backfillTimeline(ctx, requesterID, getUserFromCtx(r))
+		return nil
+	})
+}
+
+// rejectFollowRequestHandler godoc
+//
+//	@Summary		Rejects a follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"Requester ID"
+//	@Success		204		{string}	string	"Request rejected"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID}/reject [put]
+func (app *applicaion) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.Reject)
+}
+
+func (app *applicaion) answerFollowRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userID, requesterID int64) error) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := answer(r.Context(), user.ID, requesterID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canSeePostsOf tells whether the viewer may see the posts of the author:
+// neither blocked the other, and the author is public, is the viewer,
+// approved them as a follower, or the viewer is a moderator.
+func (app *applicaion) canSeePostsOf(ctx context.Context, viewer, author *store.User) (bool, error) {
+	if viewer.ID == author.ID {
+		return true, nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewer.ID, author.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+
+	if !author.IsPrivate {
+		return true, nil
+	}
+
+	following, err := app.store.Followers.IsFollowing(ctx, viewer.ID, author.ID)
+	if err != nil || following {
+		return following, err
+	}
+
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
+
+// privateProfile is what non approved viewers see of a private user.
+func privateProfile(user *store.User) *store.User {
+	return &store.User{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		IsPrivate:   true,
+
+		FollowersCount: user.FollowersCount,
+		FollowingCount: user.FollowingCount,
+		PostsCount:     user.PostsCount,
+	}
+}
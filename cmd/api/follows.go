@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// getFollowersHandler godoc
+//
+//	@Summary		Lists the followers of a user
+//	@Description	Lists the followers of a user, newest first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *applicaion) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, "followers", app.store.Followers.GetFollowers)
+}
+
+// getFollowingHandler godoc
+//
+//	@Summary		Lists the users a user follows
+//	@Description	Lists the users a user follows, newest first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.FollowListEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *applicaion) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, "following", app.store.Followers.GetFollowing)
+}
+
+type followLister func(ctx context.Context, userID, viewerID int64, q store.CursorQuery) ([]store.FollowListEntry, store.PageInfo, error)
+
+func (app *applicaion) listFollows(w http.ResponseWriter, r *http.Request, list string, lister followLister) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	scope := fmt.Sprintf("%s:%d", list, userID)
+
+	q, err := app.parseCursorQuery(r, scope, 20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := app.canSeePostsOf(ctx, viewer, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	entries, info, err := lister(ctx, user.ID, viewer.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, scope, entries, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
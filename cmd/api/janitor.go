@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// runJanitor periodically deletes data that is no longer needed until ctx is
+// cancelled.
+func (app *applicaion) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(app.config.janitor.interval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeUnactivatedUsers(ctx)
+		app.purgeDeletedUsers(ctx)
+		app.purgeExpiredExports(ctx)
+		app.failStaleExports(ctx)
+		app.trimTimelines(ctx)
+		app.purgeExpiredSessions(ctx)
+		app.purgeRevokedTokens(ctx)
+		app.purgeLoginAttempts(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *applicaion) purgeUnactivatedUsers(ctx context.Context) {
+	purged, err := app.store.Users.PurgeUnactivated(ctx, app.config.janitor.unactivatedGracePeriod)
+	if err != nil {
+		app.logger.Errorw("error purging unactivated users", "error", err)
+		return
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged unactivated users", "count", purged)
+	}
+}
+
+func (app *applicaion) purgeDeletedUsers(ctx context.Context) {
+	purged, exportPaths, err := app.store.Users.PurgeDeleted(ctx, app.config.janitor.restoreWindow, app.config.usernameRedirectPeriod)
+	if err != nil {
+		app.logger.Errorw("error purging deleted users", "error", err)
+		return
+	}
+
+	app.removeExportArchives(exportPaths)
+
+	if purged > 0 {
+		app.logger.Infow("purged deleted users", "count", purged)
+	}
+}
+
+func (app *applicaion) purgeExpiredExports(ctx context.Context) {
+	paths, err := app.store.DataExports.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("error purging expired exports", "error", err)
+		return
+	}
+
+	app.removeExportArchives(paths)
+}
+
+// failStaleExports ends the exports left pending by a process that stopped
+// during the build, the users can ask for a new one.
+func (app *applicaion) failStaleExports(ctx context.Context) {
+	// a build still running past twice its timeout was cancelled already
+	failed, err := app.store.DataExports.FailStale(ctx, time.Now().Add(-2*exportTimeout))
+	if err != nil {
+		app.logger.Errorw("error failing stale exports", "error", err)
+		return
+	}
+
+	if failed > 0 {
+		app.logger.Infow("failed stale exports", "count", failed)
+	}
+}
+
+func (app *applicaion) removeExportArchives(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			app.logger.Errorw("error removing export archive", "path", path, "error", err)
+		}
+	}
+}
+
+func (app *applicaion) trimTimelines(ctx context.Context) {
+	trimmed, err := app.store.Timelines.DeleteOlderThan(ctx, time.Now().Add(-app.config.timeline.retention))
+	if err != nil {
+		app.logger.Errorw("error trimming timelines", "error", err)
+		return
+	}
+
+	if trimmed > 0 {
+		app.logger.Infow("trimmed timelines", "count", trimmed)
+	}
+}
+
+func (app *applicaion) purgeExpiredSessions(ctx context.Context) {
+	purged, err := app.store.Sessions.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("error purging expired sessions", "error", err)
+		return
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged expired sessions", "count", purged)
+	}
+}
+
+func (app *applicaion) purgeRevokedTokens(ctx context.Context) {
+	purged, err := app.store.RevokedTokens.DeleteExpired(ctx)
+	if err != nil {
+		app.logger.Errorw("error purging revoked tokens", "error", err)
+		return
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged revoked tokens", "count", purged)
+	}
+}
+
+// purgeLoginAttempts forgets the failed logins of keys that stayed quiet for
+// longer than the longest lockout.
+func (app *applicaion) purgeLoginAttempts(ctx context.Context) {
+	quiet := max(app.config.lockout.Window, app.config.lockout.MaxDuration)
+
+	purged, err := app.store.LoginAttempts.DeleteStale(ctx, time.Now().Add(-quiet))
+	if err != nil {
+		app.logger.Errorw("error purging login attempts", "error", err)
+		return
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged login attempts", "count", purged)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/mayankpatidar275/go-social/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		Token verification keys
+//	@Description	Public keys to verify access tokens, empty when tokens are signed with a shared secret. The same keys sign the MFA challenge tokens: verifiers must only accept the tokens whose token_use claim is "access"
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Router			/.well-known/jwks.json [get]
+func (app *applicaion) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set := auth.JWKSet{Keys: []auth.JWK{}}
+	if provider, ok := app.authenticator.(auth.KeySetProvider); ok {
+		set = provider.JWKS()
+	}
+
+	// Note: not wrapped in the data envelope, the JWKS format is standard and verifiers expect it as is
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// likePostHandler godoc
+//
+//	@Summary		Likes a post
+//	@Description	Likes a post, liking it again keeps a single like
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post liked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/like [put]
+func (app *applicaion) likePostHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactToPost(w, r, app.store.Likes.Like)
+}
+
+// unlikePostHandler godoc
+//
+//	@Summary		Unlikes a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post unliked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/like [delete]
+func (app *applicaion) unlikePostHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactToPost(w, r, app.store.Likes.Unlike)
+}
+
+func (app *applicaion) reactToPost(w http.ResponseWriter, r *http.Request, react func(ctx context.Context, userID, postID int64) error) {
+	post := getPostFromCtx(r)
+	viewer := getUserFromCtx(r)
+	ctx := r.Context()
+
+	author, err := app.getUser(ctx, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	allowed, err := app.canSeePostsOf(ctx, viewer, author)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Note: same answer as a missing post, it would tell the post exists otherwise
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := react(ctx, viewer.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
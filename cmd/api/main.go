@@ -1,14 +1,23 @@
 package main
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/mayankpatidar275/go-social/internal/auth"
+	"github.com/mayankpatidar275/go-social/internal/auth/oidc"
+	"github.com/mayankpatidar275/go-social/internal/cursor"
 	"github.com/mayankpatidar275/go-social/internal/db"
 	"github.com/mayankpatidar275/go-social/internal/env"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
 	"github.com/mayankpatidar275/go-social/internal/mailer"
+	"github.com/mayankpatidar275/go-social/internal/password"
+	"github.com/mayankpatidar275/go-social/internal/ranking"
 	"github.com/mayankpatidar275/go-social/internal/ratelimiter"
 	"github.com/mayankpatidar275/go-social/internal/store"
+	"github.com/mayankpatidar275/go-social/internal/store/cache"
 	"go.uber.org/zap"
 )
 
@@ -50,10 +59,14 @@ func main() {
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
 			exp:       time.Hour * 24 * 3, // 3 days
+			resetExp:  time.Hour,
 			fromEmail: env.GetString("FROM_EMAIL", ""),
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
 			},
+			resendCooldown: time.Minute * 5,
+			resetCooldown:  time.Minute * 5,
+			emailChangeExp: time.Hour * 24,
 		},
 		auth: authConfig{
 			basic: basicConfig{
@@ -61,16 +74,61 @@ func main() {
 				pass: env.GetString("AUTH_BASIC_PASS", "admin"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
-				exp:    time.Hour * 24 * 3, // 3 days
-				iss:    "gosocial",
+				secret:     env.GetString("AUTH_TOKEN_SECRET", "example"),
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour * 24 * 30, // 30 days
+				iss:        "gosocial",
+				alg:        env.GetString("AUTH_TOKEN_ALG", "HS256"),
+				keysDir:    env.GetString("AUTH_TOKEN_KEYS_DIR", "./keys"),
+				signingKID: env.GetString("AUTH_TOKEN_SIGNING_KID", ""),
 			},
+			mfa: mfaConfig{
+				issuer:             "GoSocial",
+				challengeExp:       time.Minute * 5,
+				enforced:           env.GetBool("MFA_ENFORCED", false),
+				requiredAboveLevel: env.GetInt("MFA_REQUIRED_ABOVE_ROLE_LEVEL", 1), // moderators and admins
+			},
+			oidc: oidcConfigs(),
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestsPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS_COUNT", 20),
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		lockout: lockout.Config{
+			MaxAccountAttempts: env.GetInt("LOCKOUT_MAX_ACCOUNT_ATTEMPTS", 5),
+			MaxIPAttempts:      env.GetInt("LOCKOUT_MAX_IP_ATTEMPTS", 50),
+			Window:             time.Minute * 15,
+			BaseDuration:       time.Minute,
+			MaxDuration:        time.Hour * 24,
+			Enabled:            env.GetBool("LOCKOUT_ENABLED", true),
+		},
+		passwordPolicy: password.Policy{
+			MinLength:           env.GetInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:           72,
+			MinCharacterClasses: env.GetInt("PASSWORD_MIN_CHARACTER_CLASSES", 3),
+		},
+		cursorSecret:           env.GetString("PAGINATION_CURSOR_SECRET", "example"),
+		usernameRedirectPeriod: time.Hour * 24 * time.Duration(env.GetInt("USERNAME_REDIRECT_DAYS", 90)),
+		publicCacheTTL:         time.Second * time.Duration(env.GetInt("PUBLIC_CACHE_SECONDS", 30)),
+		trendingWindow:         time.Hour * time.Duration(env.GetInt("TRENDING_WINDOW_HOURS", 24)),
+		export: exportConfig{
+			dir:         env.GetString("EXPORT_DIR", "./exports"),
+			downloadURL: env.GetString("EXPORT_DOWNLOAD_URL", "http://localhost:8080/v1/exports"),
+			exp:         time.Hour * 24 * 7,
+			cooldown:    time.Hour,
+		},
+		timeline: timelineConfig{
+			enabled:            env.GetBool("TIMELINE_ENABLED", true),
+			fanOutMaxFollowers: env.GetInt("TIMELINE_FAN_OUT_MAX_FOLLOWERS", 10000),
+			retention:          time.Hour * 24 * time.Duration(env.GetInt("TIMELINE_RETENTION_DAYS", 30)),
+			verifyPercent:      env.GetInt("TIMELINE_VERIFY_PERCENT", 1),
+		},
+		janitor: janitorConfig{
+			interval:               time.Hour,
+			unactivatedGracePeriod: time.Hour * 24 * time.Duration(env.GetInt("UNACTIVATED_USER_GRACE_DAYS", 7)),
+			restoreWindow:          time.Hour * 24 * time.Duration(env.GetInt("DELETED_USER_RESTORE_DAYS", 30)),
+		},
 	}
 
 	// Logger
@@ -86,6 +144,16 @@ func main() {
 	defer db.Close()
 	logger.Info("database connection pool established")
 
+	// Cache
+	var cacheStorage cache.Storage
+	if cfg.redisCfg.enabled {
+		rdb := cache.NewRedisClient(cfg.redisCfg.addr, cfg.redisCfg.pw, cfg.redisCfg.db)
+		defer rdb.Close()
+		logger.Info("redis cache connection established")
+
+		cacheStorage = cache.NewRedisStorage(rdb)
+	}
+
 	// Rate limiter
 	rateLimiter := ratelimiter.NewFixedWindowLimiter(
 		cfg.rateLimiter.RequestsPerTimeFrame,
@@ -96,13 +164,30 @@ func main() {
 	// Our handlers will receive the storage
 	store := store.NewStorage(db)
 
+	// Failed logins are tracked in redis when it is available, otherwise in postgres
+	var loginAttempts lockout.Store = store.LoginAttempts
+	if cfg.redisCfg.enabled {
+		loginAttempts = cacheStorage.LoginAttempts
+	}
+	loginLockout := lockout.NewTracker(loginAttempts, cfg.lockout)
+
 	mailer := mailer.NewSendgrid(cfg.mail.sendGrid.apiKey, cfg.mail.fromEmail)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(
-		cfg.auth.token.secret,
-		cfg.auth.token.iss,
-		cfg.auth.token.iss,
-	)
+	// Authenticator
+	// the issue times keep microseconds: a token issued right after the tokens
+	// of the user were revoked falls in the same second as the cut-off
+	jwt.TimePrecision = time.Microsecond
+
+	authenticator, err := newAuthenticator(cfg.auth.token)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Identity providers for social login
+	identityProviders := make(map[string]oidc.IdentityProvider, len(cfg.auth.oidc))
+	for _, providerCfg := range cfg.auth.oidc {
+		identityProviders[providerCfg.Name] = oidc.NewProvider(providerCfg)
+	}
 
 	// Note:
 	// Type: application is the blueprint (no memory used until instantiated).
@@ -112,12 +197,63 @@ func main() {
 	app := &applicaion{
 		config:        cfg,
 		store:         store,
+		cacheStorage:  cacheStorage,
 		logger:        logger,
 		mailer:        mailer,
-		authenticator: jwtAuthenticator,
+		authenticator: authenticator,
 		rateLimiter:   rateLimiter,
+		lockout:       loginLockout,
+		cursors:       cursor.NewSigner(cfg.cursorSecret),
+		ranker:        ranking.Default(),
+
+		identityProviders: identityProviders,
 	}
 
 	mux := app.mount()
 	logger.Fatal(app.run(mux))
 }
+
+// newAuthenticator picks the token signing algorithm. RS256 and EdDSA load their
+// keys from keysDir, HS256 uses the shared secret.
+func newAuthenticator(cfg tokenConfig) (auth.Authenticator, error) {
+	switch cfg.alg {
+	case "HS256":
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.iss, cfg.iss), nil
+	case "RS256", "EdDSA":
+		keyring, err := auth.LoadKeyring(cfg.keysDir, cfg.signingKID)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.alg == "RS256" {
+			return auth.NewRS256Authenticator(keyring, cfg.iss, cfg.iss)
+		}
+		return auth.NewEdDSAAuthenticator(keyring, cfg.iss, cfg.iss)
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", cfg.alg)
+	}
+}
+
+// oidcConfigs reads the providers listed in OIDC_PROVIDERS (e.g. "google,stub"),
+// each one is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL.
+func oidcConfigs() []oidc.Config {
+	var configs []oidc.Config
+
+	for _, name := range strings.Split(env.GetString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		configs = append(configs, oidc.Config{
+			Name:         name,
+			Issuer:       env.GetString(prefix+"ISSUER", ""),
+			ClientID:     env.GetString(prefix+"CLIENT_ID", ""),
+			ClientSecret: env.GetString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  env.GetString(prefix+"REDIRECT_URL", ""),
+		})
+	}
+
+	return configs
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"github.com/mayankpatidar275/go-social/internal/totp"
+)
+
+const (
+	// every token the authenticator signs carries its use, the MFA challenges
+	// are signed with the keys published in the JWKS like the access tokens
+	tokenUseAccess = "access"
+	tokenUseMFA    = "mfa"
+
+	recoveryCodesCount = 10
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor authentication code")
+
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFACodePayload struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type VerifyMFAPayload struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// verifyMFAHandler godoc
+//
+//	@Summary		Completes a two-factor login
+//	@Description	Exchanges the MFA challenge from /authentication/token and a TOTP or recovery code for tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		VerifyMFAPayload	true	"Challenge and code"
+//	@Success		201		{object}	TokenPair
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		423		{object}	error	"Account locked"
+//	@Failure		429		{object}	error	"Too many failed attempts"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/token/mfa [post]
+func (app *applicaion) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyMFAPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if tokenUse, _ := claims["token_use"].(string); tokenUse != tokenUseMFA {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("not an mfa challenge token"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.checkTokenRevocation(ctx, userID, claims); err != nil {
+		switch err {
+		case errTokenRevoked, store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Note: a deleted account is only restored once the code is verified
+	user, err := app.store.Users.GetByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the account may have been sanctioned since the challenge was issued
+	if user.Sanctioned() {
+		app.accountSuspendedResponse(w, r, user)
+		return
+	}
+
+	// the code is a second password, guessing it counts against the same lockout
+	accountKey := lockout.AccountKey(user.Email)
+	ipKey := lockout.IPKey(r.RemoteAddr)
+
+	retryAfter, err := app.lockout.Check(ctx, accountKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.accountLockedResponse(w, r, retryAfter)
+		return
+	}
+
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.verifyMFACode(ctx, mfa, payload.Code); err != nil {
+		switch err {
+		case errInvalidMFACode:
+			app.failedLoginResponse(w, r, accountKey, ipKey, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// a challenge can only be exchanged once
+	if err := app.revokeToken(ctx, user.ID, claims); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.lockout.Reset(ctx, accountKey); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !app.restoreDeletedAccount(w, r, user) {
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user, true)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// EnrollTOTP godoc
+//
+//	@Summary		Starts TOTP enrollment
+//	@Description	Generates a TOTP secret, the provisioning URI can be rendered as a QR code for authenticator apps
+//	@Tags			mfa
+//	@Produce		json
+//	@Success		201	{object}	TOTPEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Already enabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp [post]
+func (app *applicaion) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.CreatePending(r.Context(), user.ID, secret); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(app.config.auth.mfa.issuer, user.Email, secret),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmTOTP godoc
+//
+//	@Summary		Confirms TOTP enrollment
+//	@Description	Enables two-factor authentication with a first code and returns the one-time recovery codes
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error	"No pending enrollment"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp/confirm [post]
+func (app *applicaion) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload MFACodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if mfa.Enabled() {
+		app.conflictResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	step, ok, err := totp.Validate(mfa.Secret, payload.Code, time.Now())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidMFACode)
+		return
+	}
+
+	codes, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.Enable(ctx, user.ID, step, hashed); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DisableTOTP godoc
+//
+//	@Summary		Disables two-factor authentication
+//	@Description	Disables two-factor authentication, requires a current TOTP or recovery code
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"TOTP or recovery code"
+//	@Success		204		{string}	string			"Disabled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error	"Required for the role"
+//	@Failure		404		{object}	error
+//	@Failure		423		{object}	error	"Account locked"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/totp [delete]
+func (app *applicaion) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if app.mfaRequired(user) {
+		app.mfaRequiredResponse(w, r)
+		return
+	}
+
+	mfa, ok := app.readAndVerifyMFACode(w, r, user)
+	if !ok {
+		return
+	}
+
+	if err := app.store.MFA.Disable(r.Context(), mfa.UserID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Regenerates recovery codes
+//	@Description	Replaces every recovery code, requires a current TOTP or recovery code
+//	@Tags			mfa
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"TOTP or recovery code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		423		{object}	error	"Account locked"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mfa/recovery-codes [post]
+func (app *applicaion) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	mfa, ok := app.readAndVerifyMFACode(w, r, user)
+	if !ok {
+		return
+	}
+
+	codes, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MFA.ReplaceRecoveryCodes(r.Context(), mfa.UserID, hashed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readAndVerifyMFACode reads a MFACodePayload and checks it against the enabled
+// enrollment of the user. The response is already written when it returns false.
+func (app *applicaion) readAndVerifyMFACode(w http.ResponseWriter, r *http.Request, user *store.User) (*store.MFA, bool) {
+	var payload MFACodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	ctx := r.Context()
+
+	mfa, err := app.store.MFA.Get(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if !mfa.Enabled() {
+		app.notFoundResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return nil, false
+	}
+
+	// guessing the code counts against the lockout of the login, like in verifyMFAHandler
+	accountKey := lockout.AccountKey(user.Email)
+
+	retryAfter, err := app.lockout.Check(ctx, accountKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	if retryAfter > 0 {
+		app.accountLockedResponse(w, r, retryAfter)
+		return nil, false
+	}
+
+	if err := app.verifyMFACode(ctx, mfa, payload.Code); err != nil {
+		switch err {
+		case errInvalidMFACode:
+			retryAfter, lockErr := app.recordFailedLogin(ctx, accountKey, lockout.IPKey(r.RemoteAddr))
+			if lockErr != nil {
+				app.internalServerError(w, r, lockErr)
+				return nil, false
+			}
+			if retryAfter > 0 {
+				app.accountLockedResponse(w, r, retryAfter)
+				return nil, false
+			}
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if err := app.lockout.Reset(ctx, accountKey); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	return mfa, true
+}
+
+// verifyMFACode accepts a TOTP code or, when it does not look like one, a recovery code.
+// Both can only be used once.
+func (app *applicaion) verifyMFACode(ctx context.Context, mfa *store.MFA, code string) error {
+	if len(code) == totp.Digits {
+		step, ok, err := totp.Validate(mfa.Secret, code, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInvalidMFACode
+		}
+
+		if err := app.store.MFA.UseStep(ctx, mfa.UserID, step); err != nil {
+			if err == store.ErrConflict {
+				return errInvalidMFACode
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	if err := app.store.MFA.UseRecoveryCode(ctx, mfa.UserID, hashRecoveryCode(code)); err != nil {
+		if err == store.ErrNotFound {
+			return errInvalidMFACode
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (app *applicaion) generateMFAChallenge(user *store.User) (*MFAChallenge, error) {
+	claims := jwt.MapClaims{
+		"jti":       uuid.New().String(),
+		"token_use": tokenUseMFA,
+		"sub":       user.ID,
+		"exp":       time.Now().Add(app.config.auth.mfa.challengeExp).Unix(),
+		"iat":       jwt.NewNumericDate(time.Now()),
+		"nbf":       time.Now().Unix(),
+		"iss":       app.config.auth.token.iss,
+		"aud":       app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(app.config.auth.mfa.challengeExp.Seconds()),
+	}, nil
+}
+
+// mfaRequired tells whether the role of the user is above the configured level.
+func (app *applicaion) mfaRequired(user *store.User) bool {
+	return app.config.auth.mfa.enforced && user.Role.Level > app.config.auth.mfa.requiredAboveLevel
+}
+
+func hasAMR(claims jwt.MapClaims, method string) bool {
+	amr, _ := claims["amr"].([]any)
+	for _, m := range amr {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateRecoveryCodes returns the plain codes for the user and their hashes for storage.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashed := make([]string, recoveryCodesCount)
+
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		codes[i] = code[:5] + "-" + code[5:]
+		hashed[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashed, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/lockout"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"github.com/mayankpatidar275/go-social/internal/totp"
+	"go.uber.org/zap"
+)
+
+// memoryMFAStore keeps the second factor of a single user, it follows the
+// replay and single use rules of store.MFAStore.
+type memoryMFAStore struct {
+	mfa           store.MFA
+	recoveryCodes map[string]bool
+}
+
+func (s *memoryMFAStore) Get(context.Context, int64) (*store.MFA, error) {
+	mfa := s.mfa
+	return &mfa, nil
+}
+
+func (s *memoryMFAStore) CreatePending(context.Context, int64, string) error { return nil }
+
+func (s *memoryMFAStore) Enable(context.Context, int64, int64, []string) error { return nil }
+
+func (s *memoryMFAStore) Disable(context.Context, int64) error { return nil }
+
+func (s *memoryMFAStore) UseStep(_ context.Context, _ int64, step int64) error {
+	if step <= s.mfa.LastUsedStep {
+		return store.ErrConflict
+	}
+	s.mfa.LastUsedStep = step
+	return nil
+}
+
+func (s *memoryMFAStore) UseRecoveryCode(_ context.Context, _ int64, code string) error {
+	if !s.recoveryCodes[code] {
+		return store.ErrNotFound
+	}
+	delete(s.recoveryCodes, code)
+	return nil
+}
+
+func (s *memoryMFAStore) ReplaceRecoveryCodes(_ context.Context, _ int64, codes []string) error {
+	s.recoveryCodes = make(map[string]bool)
+	for _, code := range codes {
+		s.recoveryCodes[code] = true
+	}
+	return nil
+}
+
+func newMFATestApp(t *testing.T) (*applicaion, *memoryMFAStore) {
+	t.Helper()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfaStore := &memoryMFAStore{mfa: store.MFA{UserID: 1, Secret: secret}}
+	app := &applicaion{store: store.Storage{MFA: mfaStore}}
+
+	return app, mfaStore
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodesCount || len(hashed) != recoveryCodesCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashed), recoveryCodesCount)
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("code %q is repeated", code)
+		}
+		seen[code] = true
+
+		if len(code) == totp.Digits {
+			t.Errorf("code %q could be taken for a totp code", code)
+		}
+		if hashed[i] != hashRecoveryCode(code) {
+			t.Errorf("hash of code %q = %s, want %s", code, hashed[i], hashRecoveryCode(code))
+		}
+		if hashed[i] == code {
+			t.Errorf("code %q is stored in clear", code)
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := hashRecoveryCode("abcde-fghij")
+
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde-fghij\n", "AbCdE-fGhIj"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hash of %q = %s, want %s", code, got, want)
+		}
+	}
+
+	if hashRecoveryCode("abcde-fghik") == want {
+		t.Error("two codes have the same hash")
+	}
+}
+
+func TestVerifyMFACode(t *testing.T) {
+	ctx := context.Background()
+
+	codeAt := func(t *testing.T, secret string, at time.Time) string {
+		code, err := totp.Code(secret, totp.Step(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	t.Run("totp codes are single use", func(t *testing.T) {
+		app, mfaStore := newMFATestApp(t)
+		code := codeAt(t, mfaStore.mfa.Secret, time.Now())
+
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, code); err != nil {
+			t.Fatalf("first use: %v", err)
+		}
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, code); err != errInvalidMFACode {
+			t.Fatalf("replay: got %v, want errInvalidMFACode", err)
+		}
+	})
+
+	t.Run("older steps are rejected after a newer one", func(t *testing.T) {
+		app, mfaStore := newMFATestApp(t)
+		now := time.Now()
+
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, codeAt(t, mfaStore.mfa.Secret, now.Add(totp.Period))); err != nil {
+			t.Fatalf("next step: %v", err)
+		}
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, codeAt(t, mfaStore.mfa.Secret, now)); err != errInvalidMFACode {
+			t.Fatalf("current step: got %v, want errInvalidMFACode", err)
+		}
+	})
+
+	t.Run("wrong totp code", func(t *testing.T) {
+		app, mfaStore := newMFATestApp(t)
+		code := codeAt(t, mfaStore.mfa.Secret, time.Now().Add(-time.Hour))
+
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, code); err != errInvalidMFACode {
+			t.Fatalf("got %v, want errInvalidMFACode", err)
+		}
+	})
+
+	t.Run("recovery codes are single use", func(t *testing.T) {
+		app, mfaStore := newMFATestApp(t)
+
+		codes, hashed, err := generateRecoveryCodes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mfaStore.ReplaceRecoveryCodes(ctx, 1, hashed); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, strings.ToUpper(codes[0])); err != nil {
+			t.Fatalf("first use: %v", err)
+		}
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, codes[0]); err != errInvalidMFACode {
+			t.Fatalf("second use: got %v, want errInvalidMFACode", err)
+		}
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, codes[1]); err != nil {
+			t.Fatalf("another code: %v", err)
+		}
+		if err := app.verifyMFACode(ctx, &mfaStore.mfa, "aaaaa-aaaaa"); err != errInvalidMFACode {
+			t.Fatalf("unknown code: got %v, want errInvalidMFACode", err)
+		}
+	})
+}
+
+func TestMFACodeGuessesCountAgainstTheLockout(t *testing.T) {
+	user := &store.User{ID: 1, Email: "alice@example.com"}
+
+	handlers := []struct {
+		name    string
+		handler func(app *applicaion) http.HandlerFunc
+	}{
+		{"disable", func(app *applicaion) http.HandlerFunc { return app.disableTOTPHandler }},
+		{"regenerate recovery codes", func(app *applicaion) http.HandlerFunc { return app.regenerateRecoveryCodesHandler }},
+	}
+
+	for _, h := range handlers {
+		t.Run(h.name, func(t *testing.T) {
+			app, mfaStore := newMFATestApp(t)
+			enabledAt := time.Now()
+			mfaStore.mfa.EnabledAt = &enabledAt
+
+			app.logger = zap.NewNop().Sugar()
+			app.config.lockout = lockout.Config{
+				MaxAccountAttempts: 3,
+				MaxIPAttempts:      100,
+				Window:             time.Hour,
+				BaseDuration:       time.Minute,
+				MaxDuration:        time.Hour,
+				Enabled:            true,
+			}
+			attempts := &memoryLoginAttemptStore{attempts: make(map[string]*store.LoginAttempt)}
+			app.lockout = lockout.NewTracker(attempts, app.config.lockout)
+
+			send := func(code string) int {
+				body, err := json.Marshal(MFACodePayload{Code: code})
+				if err != nil {
+					t.Fatal(err)
+				}
+				r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+				r = r.WithContext(context.WithValue(r.Context(), userCtx, user))
+				w := httptest.NewRecorder()
+				h.handler(app)(w, r)
+				return w.Code
+			}
+
+			wrong, err := totp.Code(mfaStore.mfa.Secret, totp.Step(time.Now().Add(-time.Hour)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 1; i < app.config.lockout.MaxAccountAttempts; i++ {
+				if code := send(wrong); code != http.StatusBadRequest {
+					t.Fatalf("wrong code %d: status %d, want %d", i, code, http.StatusBadRequest)
+				}
+			}
+			if code := send(wrong); code != http.StatusLocked {
+				t.Fatalf("last wrong code: status %d, want %d", code, http.StatusLocked)
+			}
+
+			right, err := totp.Code(mfaStore.mfa.Secret, totp.Step(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := send(right); code != http.StatusLocked {
+				t.Fatalf("right code while locked: status %d, want %d", code, http.StatusLocked)
+			}
+
+			// the login of the account is locked as well
+			retryAfter, err := app.lockout.Check(context.Background(), lockout.AccountKey(user.Email))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retryAfter <= 0 {
+				t.Fatal("the account isn't locked")
+			}
+		})
+	}
+}
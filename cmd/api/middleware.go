@@ -3,30 +3,79 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/mayankpatidar275/go-social/internal/store"
 )
 
+// AuthTokenMiddleware accepts access tokens as well as personal access tokens.
+// Routes open to personal access tokens declare the scope they need with
+// requireScope.
 func (app *applicaion) AuthTokenMiddleware(next http.Handler) http.Handler {
+	jwtAuth := app.authTokenMiddleware(next, true)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("authorization header is missing"))
+		token, err := bearerToken(r)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
+			return
+		}
+
+		if !strings.HasPrefix(token, personalAccessTokenPrefix) {
+			jwtAuth.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		pat, err := app.store.AccessTokens.GetByToken(ctx, token)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		user, err := app.getUser(ctx, pat.UserID)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("authorization header is malformed"))
+		if user.Sanctioned() {
+			app.accountSuspendedResponse(w, r, user)
+			return
+		}
+
+		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, accessTokenCtx, pat)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// MFAEnrollmentAuthMiddleware lets in users whose role requires two-factor
+// authentication but who did not enroll yet, so they can set it up.
+func (app *applicaion) MFAEnrollmentAuthMiddleware(next http.Handler) http.Handler {
+	return app.authTokenMiddleware(next, false)
+}
+
+func (app *applicaion) authTokenMiddleware(next http.Handler, requireMFA bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := bearerToken(r)
+		if err != nil {
+			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		token := parts[1]
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -35,6 +84,12 @@ func (app *applicaion) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		claims, _ := jwtToken.Claims.(jwt.MapClaims)
 
+		// challenge tokens are signed by the same authenticator but only grant the second login step
+		if tokenUse, _ := claims["token_use"].(string); tokenUse != tokenUseAccess {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("not an access token"))
+			return
+		}
+
 		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -43,6 +98,33 @@ func (app *applicaion) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
+		if err := app.checkTokenRevocation(ctx, userID, claims); err != nil {
+			switch err {
+			case errTokenRevoked, store.ErrNotFound:
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// every access token belongs to a session, ending the session ends the token too
+		sid, _ := claims["sid"].(string)
+		if sid == "" {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("token is not bound to a session"))
+			return
+		}
+
+		if err := app.store.Sessions.Touch(ctx, userID, sid); err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
 		// user, err := app.store.Users.GetByID(ctx, userID)
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
@@ -50,11 +132,64 @@ func (app *applicaion) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if user.Sanctioned() {
+			app.accountSuspendedResponse(w, r, user)
+			return
+		}
+
+		if requireMFA && app.mfaRequired(user) && !hasAMR(claims, "otp") {
+			app.mfaRequiredResponse(w, r)
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", fmt.Errorf("authorization header is missing")
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", fmt.Errorf("authorization header is malformed")
+	}
+
+	return parts[1], nil
+}
+
+// requireScope rejects personal access tokens missing the scope. Access
+// tokens from a login are not limited.
+func (app *applicaion) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pat := getAccessTokenFromCtx(r); pat != nil && !pat.HasScope(scope) {
+				app.insufficientScopeResponse(w, r, scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSessionMiddleware keeps personal access tokens away from routes that
+// manage credentials, a leaked token must not be able to mint new ones.
+func (app *applicaion) requireSessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAccessTokenFromCtx(r) != nil {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *applicaion) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -119,6 +254,26 @@ func (app *applicaion) checkPostOwnership(requiredRole string, next http.Handler
 	})
 }
 
+// requireRoleMiddleware lets through users with at least the given role.
+func (app *applicaion) requireRoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromCtx(r), requiredRole)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *applicaion) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -151,6 +306,105 @@ func (app *applicaion) getUser(ctx context.Context, userID int64) (*store.User,
 
 	return user, nil
 }
+
+// invalidateUser drops the cached copy of a user after it changed.
+func (app *applicaion) invalidateUser(ctx context.Context, userID int64) error {
+	if !app.config.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStorage.Users.Delete(ctx, userID)
+}
+
+var errTokenRevoked = errors.New("token has been revoked")
+
+// checkTokenRevocation rejects tokens that were revoked one by one (logout) or
+// issued before the user's tokens_valid_after cut-off (logout everywhere).
+func (app *applicaion) checkTokenRevocation(ctx context.Context, userID int64, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errTokenRevoked
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return err
+	}
+
+	revoked, err := app.isTokenRevoked(ctx, jti, exp.Time)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errTokenRevoked
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return errTokenRevoked
+	}
+
+	validAfter, err := app.getTokensValidAfter(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// Note: both are kept to the microsecond, the tokens issued right after
+	// the cut-off in the same second stay valid
+	if iat.Before(validAfter) {
+		return errTokenRevoked
+	}
+
+	return nil
+}
+
+func (app *applicaion) isTokenRevoked(ctx context.Context, jti string, exp time.Time) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.IsRevoked(ctx, jti)
+	}
+
+	revoked, found, err := app.cacheStorage.RevokedTokens.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	if !found {
+		revoked, err = app.store.RevokedTokens.IsRevoked(ctx, jti)
+		if err != nil {
+			return false, err
+		}
+
+		if err := app.cacheStorage.RevokedTokens.SetRevoked(ctx, jti, revoked, time.Until(exp)); err != nil {
+			return false, err
+		}
+	}
+
+	return revoked, nil
+}
+
+func (app *applicaion) getTokensValidAfter(ctx context.Context, userID int64) (time.Time, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.RevokedTokens.GetValidAfter(ctx, userID)
+	}
+
+	validAfter, found, err := app.cacheStorage.RevokedTokens.GetValidAfter(ctx, userID)
+	if err != nil {
+		return validAfter, err
+	}
+
+	if !found {
+		validAfter, err = app.store.RevokedTokens.GetValidAfter(ctx, userID)
+		if err != nil {
+			return validAfter, err
+		}
+
+		if err := app.cacheStorage.RevokedTokens.SetValidAfter(ctx, userID, validAfter); err != nil {
+			return validAfter, err
+		}
+	}
+
+	return validAfter, nil
+}
+
 func (app *applicaion) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
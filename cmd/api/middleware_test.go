@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mayankpatidar275/go-social/internal/auth"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	// like main, the tokens keep their issue time in microseconds
+	jwt.TimePrecision = time.Microsecond
+	os.Exit(m.Run())
+}
+
+// memoryRevokedTokenStore keeps the cut-off of a single user.
+type memoryRevokedTokenStore struct {
+	validAfter time.Time
+}
+
+func (s *memoryRevokedTokenStore) Revoke(context.Context, string, int64, time.Time) error {
+	return nil
+}
+
+func (s *memoryRevokedTokenStore) IsRevoked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryRevokedTokenStore) RevokeAll(context.Context, int64) (time.Time, error) {
+	s.validAfter = time.Now().Truncate(time.Microsecond)
+	return s.validAfter, nil
+}
+
+func (s *memoryRevokedTokenStore) GetValidAfter(context.Context, int64) (time.Time, error) {
+	return s.validAfter, nil
+}
+
+func (s *memoryRevokedTokenStore) DeleteExpired(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestRevokeAllTokensInTheSameSecond(t *testing.T) {
+	app := &applicaion{
+		config: config{auth: authConfig{token: tokenConfig{exp: time.Hour, iss: "gosocial"}}},
+		store: store.Storage{
+			RevokedTokens: &memoryRevokedTokenStore{},
+		},
+		authenticator: auth.NewJWTAuthenticator("secret", "gosocial", "gosocial"),
+	}
+
+	user := &store.User{ID: 1}
+	ctx := context.Background()
+
+	claimsOf := func(token string) jwt.MapClaims {
+		t.Helper()
+		jwtToken, err := app.authenticator.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jwtToken.Claims.(jwt.MapClaims)
+	}
+
+	issue := func() jwt.MapClaims {
+		t.Helper()
+		token, err := app.generateAccessToken(user, &store.RefreshToken{FamilyID: "family"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimsOf(token)
+	}
+
+	// keep the three steps apart by more than the precision of the times
+	before := issue()
+	time.Sleep(time.Millisecond)
+	if err := app.revokeAllTokens(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	after := issue()
+
+	if err := app.checkTokenRevocation(ctx, user.ID, before); err != errTokenRevoked {
+		t.Errorf("token issued before the cut-off: got %v, want errTokenRevoked", err)
+	}
+	if err := app.checkTokenRevocation(ctx, user.ID, after); err != nil {
+		t.Errorf("token issued after the cut-off: %v", err)
+	}
+}
+
+func TestIssueTimeKeepsMicroseconds(t *testing.T) {
+	app := &applicaion{
+		config:        config{auth: authConfig{token: tokenConfig{exp: time.Hour, iss: "gosocial"}}},
+		authenticator: auth.NewJWTAuthenticator("secret", "gosocial", "gosocial"),
+	}
+
+	start := time.Now().Truncate(time.Microsecond)
+	token, err := app.generateAccessToken(&store.User{ID: 1}, &store.RefreshToken{FamilyID: "family"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Now()
+
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iat, err := jwtToken.Claims.GetIssuedAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second precision would put it before start most of the time, the
+	// float conversion may lose a microsecond
+	if iat.Time.Before(start.Add(-time.Microsecond)) || iat.Time.After(end) {
+		t.Fatalf("iat = %v, want between %v and %v", iat.Time, start, end)
+	}
+}
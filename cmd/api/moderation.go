@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var (
+	errNotSanctioned = errors.New("the account is not suspended or banned")
+	errAppealOpen    = errors.New("the sanction was appealed already")
+)
+
+type SuspendUserPayload struct {
+	DurationHours int    `json:"duration_hours" validate:"required,gte=1,lte=8760"`
+	Reason        string `json:"reason" validate:"required,max=1000"`
+}
+
+type ModerationPayload struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type AppealPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+	Note     string `json:"note" validate:"required,max=2000"`
+}
+
+// suspendUserHandler godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Keeps the user out of the API for the given duration, existing tokens are revoked
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		SuspendUserPayload	true	"Duration and reason"
+//	@Success		200		{object}	store.ModerationAction
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspend [post]
+func (app *applicaion) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SuspendUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(payload.DurationHours) * time.Hour)
+
+	app.moderate(w, r, app.store.Moderation.Suspend, &store.ModerationAction{
+		Reason:    payload.Reason,
+		ExpiresAt: &expiresAt,
+	})
+}
+
+// banUserHandler godoc
+//
+//	@Summary		Bans a user
+//	@Description	Keeps the user out of the API until the ban is lifted and hides their content
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		ModerationPayload	true	"Reason"
+//	@Success		200		{object}	store.ModerationAction
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/ban [post]
+func (app *applicaion) banUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ModerationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.moderate(w, r, app.store.Moderation.Ban, &store.ModerationAction{Reason: payload.Reason})
+}
+
+// liftSanctionHandler godoc
+//
+//	@Summary		Lifts a suspension or ban
+//	@Description	Moderators lift suspensions, a ban can only be lifted by an admin
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		ModerationPayload	true	"Reason"
+//	@Success		200		{object}	store.ModerationAction
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/lift [post]
+func (app *applicaion) liftSanctionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ModerationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// the store sets the action, moderate needs it beforehand to check who lifts a ban
+	app.moderate(w, r, app.store.Moderation.Lift, &store.ModerationAction{Action: store.ModerationLift, Reason: payload.Reason})
+}
+
+// getModerationHistoryHandler godoc
+//
+//	@Summary		Lists the moderation history of a user
+//	@Description	Lists every sanction, lift and appeal of the user, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{array}		store.ModerationAction
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/moderation [get]
+func (app *applicaion) getModerationHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	actions, err := app.store.Moderation.GetByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, actions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// appealHandler godoc
+//
+//	@Summary		Appeals a suspension or ban
+//	@Description	Lets a sanctioned user, who can no longer log in, leave a note for the moderators
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		AppealPayload	true	"Credentials and appeal note"
+//	@Success		202		{string}	string			"Appeal recorded"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"The sanction was appealed already"
+//	@Failure		423		{object}	error	"Account locked"
+//	@Failure		429		{object}	error	"Too many failed attempts"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/appeal [post]
+func (app *applicaion) appealHandler(w http.ResponseWriter, r *http.Request) {
+	var payload AppealPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, ok := app.checkCredentials(w, r, payload.Email, payload.Password)
+	if !ok {
+		return
+	}
+
+	if !user.Sanctioned() {
+		app.badRequestResponse(w, r, errNotSanctioned)
+		return
+	}
+
+	action := &store.ModerationAction{
+		UserID:     user.ID,
+		Reason:     user.ModerationReason,
+		AppealNote: payload.Note,
+	}
+	if err := app.store.Moderation.Appeal(r.Context(), action); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, errAppealOpen)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// moderate applies the action of the current moderator to the user of the
+// route. Moderators can only act on users with a lower role than their own.
+func (app *applicaion) moderate(w http.ResponseWriter, r *http.Request, apply func(context.Context, *store.ModerationAction) error, action *store.ModerationAction) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserFromCtx(r)
+
+	target, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if target.Role.Level >= moderator.Role.Level {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// only admins ban, so only they lift a ban
+	if action.Action == store.ModerationLift && target.BannedAt != nil {
+		allowed, err := app.checkRolePrecedence(ctx, moderator, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	action.UserID = target.ID
+	action.ModeratorID = &moderator.ID
+
+	if err := apply(ctx, action); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if action.Action != store.ModerationLift {
+		if err := app.revokeAllTokens(ctx, target.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.invalidateUser(ctx, target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("moderation action", "action", action.Action, "user", target.ID, "moderator", moderator.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, action); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
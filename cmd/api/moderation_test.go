@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+var testRoles = map[string]store.Role{
+	"user":      {ID: 1, Name: "user", Level: 1},
+	"moderator": {ID: 2, Name: "moderator", Level: 2},
+	"admin":     {ID: 3, Name: "admin", Level: 3},
+}
+
+type memoryRoleStore struct{}
+
+func (memoryRoleStore) GetByName(_ context.Context, name string) (*store.Role, error) {
+	role, ok := testRoles[name]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &role, nil
+}
+
+// memoryModerationStore applies the sanctions to the users of memoryUserStore
+// and keeps the audit log, following the appeal rule of store.ModerationStore.
+type memoryModerationStore struct {
+	users   *memoryUserStore
+	actions []store.ModerationAction
+}
+
+func (s *memoryModerationStore) Suspend(_ context.Context, action *store.ModerationAction) error {
+	action.Action = store.ModerationSuspend
+	s.users.users[action.UserID].SuspendedUntil = action.ExpiresAt
+	return s.record(action)
+}
+
+func (s *memoryModerationStore) Ban(_ context.Context, action *store.ModerationAction) error {
+	action.Action = store.ModerationBan
+	now := time.Now()
+	s.users.users[action.UserID].BannedAt = &now
+	return s.record(action)
+}
+
+func (s *memoryModerationStore) Lift(_ context.Context, action *store.ModerationAction) error {
+	action.Action = store.ModerationLift
+	user := s.users.users[action.UserID]
+	user.SuspendedUntil = nil
+	user.BannedAt = nil
+	return s.record(action)
+}
+
+func (s *memoryModerationStore) Appeal(_ context.Context, action *store.ModerationAction) error {
+	action.Action = store.ModerationAppeal
+	for i := len(s.actions) - 1; i >= 0; i-- {
+		a := s.actions[i]
+		if a.UserID != action.UserID {
+			continue
+		}
+		if a.Action == store.ModerationAppeal {
+			return store.ErrConflict
+		}
+		if a.Action == store.ModerationSuspend || a.Action == store.ModerationBan {
+			break
+		}
+	}
+	return s.record(action)
+}
+
+func (s *memoryModerationStore) GetByUserID(context.Context, int64) ([]store.ModerationAction, error) {
+	return s.actions, nil
+}
+
+func (s *memoryModerationStore) record(action *store.ModerationAction) error {
+	action.ID = int64(len(s.actions) + 1)
+	s.actions = append(s.actions, *action)
+	return nil
+}
+
+type moderationTest struct {
+	t          *testing.T
+	router     http.Handler
+	users      *memoryUserStore
+	moderation *memoryModerationStore
+}
+
+// newModerationTest serves the moderation routes, as the user of the
+// X-Test-User header, to an admin (1), a moderator (2) and two users (3, 4).
+// Only alice (3) has a password, for the appeals.
+func newModerationTest(t *testing.T) *moderationTest {
+	t.Helper()
+
+	users := &memoryUserStore{users: map[int64]*store.User{
+		1: {ID: 1, Username: "admin", Email: "admin@example.com", Role: testRoles["admin"]},
+		2: {ID: 2, Username: "moderator", Email: "moderator@example.com", Role: testRoles["moderator"]},
+		3: {ID: 3, Username: "alice", Email: "alice@example.com", Role: testRoles["user"]},
+		4: {ID: 4, Username: "bob", Email: "bob@example.com", Role: testRoles["user"]},
+	}}
+	if err := users.users[3].Password.Set("correct horse battery staple"); err != nil {
+		t.Fatal(err)
+	}
+
+	moderation := &memoryModerationStore{users: users}
+
+	app := &applicaion{
+		store: store.Storage{
+			Users:         users,
+			Roles:         memoryRoleStore{},
+			Moderation:    moderation,
+			RevokedTokens: &memoryRevokedTokenStore{},
+		},
+		logger:  zap.NewNop().Sugar(),
+		lockout: lockout.NewTracker(nil, lockout.Config{}),
+	}
+
+	// stands in for AuthTokenMiddleware
+	asUser := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := strconv.ParseInt(r.Header.Get("X-Test-User"), 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(r.Context(), userCtx, users.users[userID])
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	r := chi.NewRouter()
+	r.Route("/admin/users/{userID}", func(r chi.Router) {
+		r.Use(asUser, app.requireRoleMiddleware("moderator"))
+		r.Post("/suspend", app.suspendUserHandler)
+		r.With(app.requireRoleMiddleware("admin")).Post("/ban", app.banUserHandler)
+		r.Post("/lift", app.liftSanctionHandler)
+	})
+	r.Post("/authentication/appeal", app.appealHandler)
+
+	return &moderationTest{t: t, router: r, users: users, moderation: moderation}
+}
+
+// do posts the payload to path, as no one when asUserID is zero.
+func (m *moderationTest) do(asUserID int64, path string, payload any) int {
+	m.t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if asUserID != 0 {
+		req.Header.Set("X-Test-User", strconv.FormatInt(asUserID, 10))
+	}
+
+	rr := httptest.NewRecorder()
+	m.router.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestModerationRoles(t *testing.T) {
+	reason := ModerationPayload{Reason: "spam"}
+	suspension := SuspendUserPayload{DurationHours: 24, Reason: "spam"}
+
+	tests := []struct {
+		name      string
+		moderator int64
+		path      string
+		payload   any
+		status    int
+	}{
+		{"moderator suspends a user", 2, "/admin/users/3/suspend", suspension, http.StatusOK},
+		{"moderator can't ban", 2, "/admin/users/3/ban", reason, http.StatusForbidden},
+		{"admin bans a user", 1, "/admin/users/3/ban", reason, http.StatusOK},
+		{"moderator can't suspend another moderator", 2, "/admin/users/2/suspend", suspension, http.StatusForbidden},
+		{"moderator can't suspend an admin", 2, "/admin/users/1/suspend", suspension, http.StatusForbidden},
+		{"user can't moderate", 4, "/admin/users/3/suspend", suspension, http.StatusForbidden},
+		{"unknown user", 1, "/admin/users/99/ban", reason, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newModerationTest(t)
+			if status := m.do(tt.moderator, tt.path, tt.payload); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestOnlyAdminsLiftABan(t *testing.T) {
+	m := newModerationTest(t)
+	reason := ModerationPayload{Reason: "reviewed"}
+
+	if status := m.do(1, "/admin/users/3/ban", ModerationPayload{Reason: "spam"}); status != http.StatusOK {
+		t.Fatalf("ban: status %d", status)
+	}
+
+	if status := m.do(2, "/admin/users/3/lift", reason); status != http.StatusForbidden {
+		t.Fatalf("moderator lifts a ban: status %d, want %d", status, http.StatusForbidden)
+	}
+	if m.users.users[3].BannedAt == nil {
+		t.Fatal("the moderator lifted the ban")
+	}
+
+	if status := m.do(1, "/admin/users/3/lift", reason); status != http.StatusOK {
+		t.Fatalf("admin lifts a ban: status %d, want %d", status, http.StatusOK)
+	}
+	if m.users.users[3].BannedAt != nil {
+		t.Fatal("the ban is still there")
+	}
+}
+
+func TestModeratorsLiftASuspension(t *testing.T) {
+	m := newModerationTest(t)
+
+	if status := m.do(2, "/admin/users/3/suspend", SuspendUserPayload{DurationHours: 24, Reason: "spam"}); status != http.StatusOK {
+		t.Fatalf("suspend: status %d", status)
+	}
+
+	if status := m.do(2, "/admin/users/3/lift", ModerationPayload{Reason: "reviewed"}); status != http.StatusOK {
+		t.Fatalf("lift: status %d, want %d", status, http.StatusOK)
+	}
+	if m.users.users[3].Sanctioned() {
+		t.Fatal("the user is still suspended")
+	}
+}
+
+func TestAppealOncePerSanction(t *testing.T) {
+	m := newModerationTest(t)
+
+	appeal := AppealPayload{Email: "alice@example.com", Password: "correct horse battery staple", Note: "it wasn't spam"}
+
+	if status := m.do(0, "/authentication/appeal", appeal); status != http.StatusBadRequest {
+		t.Fatalf("appeal without a sanction: status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status := m.do(2, "/admin/users/3/suspend", SuspendUserPayload{DurationHours: 24, Reason: "spam"}); status != http.StatusOK {
+		t.Fatalf("suspend: status %d", status)
+	}
+
+	if status := m.do(0, "/authentication/appeal", appeal); status != http.StatusAccepted {
+		t.Fatalf("first appeal: status %d, want %d", status, http.StatusAccepted)
+	}
+	if status := m.do(0, "/authentication/appeal", appeal); status != http.StatusConflict {
+		t.Fatalf("second appeal: status %d, want %d", status, http.StatusConflict)
+	}
+
+	wrongPassword := appeal
+	wrongPassword.Password = "wrong"
+	if status := m.do(0, "/authentication/appeal", wrongPassword); status != http.StatusUnauthorized {
+		t.Fatalf("appeal with a wrong password: status %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// a new sanction can be appealed again
+	if status := m.do(1, "/admin/users/3/ban", ModerationPayload{Reason: "spam again"}); status != http.StatusOK {
+		t.Fatalf("ban: status %d", status)
+	}
+	if status := m.do(0, "/authentication/appeal", appeal); status != http.StatusAccepted {
+		t.Fatalf("appeal of the ban: status %d, want %d", status, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/auth/oidc"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+const (
+	// oidcStateExp is how long the user has to log in at the provider
+	oidcStateExp = time.Minute * 10
+
+	maxUsernameCollisions = 5
+)
+
+var (
+	errUnknownProvider   = errors.New("unknown identity provider")
+	errEmailNotVerified  = errors.New("the identity provider did not verify the email address")
+	usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type OIDCCallbackPayload struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=255"`
+}
+
+// oidcAuthorizeHandler godoc
+//
+//	@Summary		Starts a social login
+//	@Description	Returns the provider URL the user has to be redirected to, the provider then redirects back with a code and state
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		200			{object}	OIDCAuthorization
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider} [get]
+func (app *applicaion) oidcAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.identityProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errUnknownProvider)
+		return
+	}
+
+	state, errState := oidc.RandomString()
+	nonce, errNonce := oidc.RandomString()
+	verifier, errVerifier := oidc.RandomString()
+	if err := errors.Join(errState, errNonce, errVerifier); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	oidcState := &store.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Expiry:       time.Now().Add(oidcStateExp),
+	}
+
+	if err := app.store.OIDCStates.Create(ctx, state, oidcState); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallengeS256(verifier))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, OIDCAuthorization{AuthorizationURL: authURL}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes a social login
+//	@Description	Exchanges the code from the provider redirect, creates or links the user and returns tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			payload		body		OIDCCallbackPayload	true	"Code and state from the redirect"
+//	@Success		201			{object}	TokenPair
+//	@Success		200			{object}	MFAChallenge	"Two-factor authentication required"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [post]
+func (app *applicaion) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.identityProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errUnknownProvider)
+		return
+	}
+
+	var payload OIDCCallbackPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	oidcState, err := app.store.OIDCStates.Consume(ctx, payload.State)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if oidcState.Provider != provider.Name() {
+		app.unauthorizedErrorResponse(w, r, errors.New("state was issued for another provider"))
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, payload.Code, oidcState.CodeVerifier, oidcState.Nonce)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.userFromIdentity(ctx, identity)
+	if err != nil {
+		switch err {
+		case errEmailNotVerified:
+			app.badRequestResponse(w, r, err)
+		case store.ErrConflict, store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// userFromIdentity returns the user already linked to the identity. Otherwise
+// the identity is linked to the user with the same email, or a new user is
+// created. Both require an email verified by the provider.
+func (app *applicaion) userFromIdentity(ctx context.Context, identity *oidc.Identity) (*store.User, error) {
+	user, err := app.store.Users.GetByIdentity(ctx, identity.Provider, identity.Subject)
+	if err != store.ErrNotFound {
+		return user, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errEmailNotVerified
+	}
+
+	userIdentity := &store.UserIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	err = app.store.Users.LinkIdentityByEmail(ctx, userIdentity)
+	switch err {
+	case nil:
+		return app.store.Users.GetByID(ctx, userIdentity.UserID)
+	case store.ErrNotFound:
+		return app.createUserFromIdentity(ctx, identity, userIdentity)
+	default:
+		return nil, err
+	}
+}
+
+func (app *applicaion) createUserFromIdentity(ctx context.Context, identity *oidc.Identity, userIdentity *store.UserIdentity) (*store.User, error) {
+	// the user logs in through the provider, the password can be set later with a reset
+	randomPassword, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	base := usernameFromIdentity(identity)
+	username := base
+
+	for i := 0; i < maxUsernameCollisions; i++ {
+		user := &store.User{
+			Username: username,
+			Email:    identity.Email,
+			Role: store.Role{
+				Name: "user",
+			},
+		}
+
+		if err := user.Password.Set(randomPassword); err != nil {
+			return nil, err
+		}
+
+		err := app.store.Users.CreateWithIdentity(ctx, user, userIdentity)
+		if err != store.ErrDuplicateUsername {
+			if err != nil {
+				return nil, err
+			}
+			return app.store.Users.GetByID(ctx, user.ID)
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		username = base + "-" + hex.EncodeToString(suffix)
+	}
+
+	return nil, store.ErrDuplicateUsername
+}
+
+func usernameFromIdentity(identity *oidc.Identity) string {
+	username := identity.PreferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	username = usernameInvalidChars.ReplaceAllString(username, "")
+	if len(username) > 90 {
+		username = username[:90]
+	}
+	if username == "" || isReservedUsername(username) {
+		username = "user"
+	}
+
+	return username
+}
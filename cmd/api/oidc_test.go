@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/auth"
+	"github.com/mayankpatidar275/go-social/internal/auth/oidc"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryUserStore keeps the users and identities the social login and the
+// moderation need, the other methods of the embedded store are not used.
+type memoryUserStore struct {
+	*store.UserStore
+	users      map[int64]*store.User
+	identities map[string]int64
+}
+
+func (s *memoryUserStore) GetByID(_ context.Context, userID int64) (*store.User, error) {
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memoryUserStore) GetByEmailIncludingDeleted(_ context.Context, email string) (*store.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *memoryUserStore) GetByIdentity(ctx context.Context, provider, subject string) (*store.User, error) {
+	userID, ok := s.identities[provider+"|"+subject]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return s.GetByID(ctx, userID)
+}
+
+func (s *memoryUserStore) LinkIdentityByEmail(_ context.Context, identity *store.UserIdentity) error {
+	for _, user := range s.users {
+		if user.Email == identity.Email {
+			identity.UserID = user.ID
+			s.identities[identity.Provider+"|"+identity.Subject] = user.ID
+			user.IsActive = true
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *memoryUserStore) CreateWithIdentity(_ context.Context, user *store.User, identity *store.UserIdentity) error {
+	for _, u := range s.users {
+		if u.Username == user.Username {
+			return store.ErrDuplicateUsername
+		}
+	}
+
+	user.ID = int64(len(s.users) + 1)
+	user.IsActive = true
+	s.users[user.ID] = user
+
+	identity.UserID = user.ID
+	s.identities[identity.Provider+"|"+identity.Subject] = user.ID
+	return nil
+}
+
+type memoryOIDCStateStore struct {
+	states map[string]store.OIDCState
+}
+
+func (s *memoryOIDCStateStore) Create(_ context.Context, state string, oidcState *store.OIDCState) error {
+	s.states[state] = *oidcState
+	return nil
+}
+
+func (s *memoryOIDCStateStore) Consume(_ context.Context, state string) (*store.OIDCState, error) {
+	oidcState, ok := s.states[state]
+	delete(s.states, state)
+	if !ok || oidcState.Expiry.Before(time.Now()) {
+		return nil, store.ErrNotFound
+	}
+	return &oidcState, nil
+}
+
+// memorySessionStore only starts sessions.
+type memorySessionStore struct {
+	*store.SessionStore
+}
+
+func (s *memorySessionStore) Create(_ context.Context, session *store.Session, _ string, rt *store.RefreshToken) error {
+	rt.FamilyID = session.ID
+	rt.UserID = session.UserID
+	return nil
+}
+
+type oidcTest struct {
+	t      *testing.T
+	router http.Handler
+	users  *memoryUserStore
+	states *memoryOIDCStateStore
+}
+
+// newOIDCTest runs the stub issuer and an app logging in through it.
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	var issuer http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	stub, err := oidc.NewStubIssuer(srv.URL, "gosocial", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer = stub.Handler()
+
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "stub",
+		Issuer:       srv.URL,
+		ClientID:     "gosocial",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:5173/oauth/stub",
+	})
+
+	users := &memoryUserStore{users: make(map[int64]*store.User), identities: make(map[string]int64)}
+	states := &memoryOIDCStateStore{states: make(map[string]store.OIDCState)}
+
+	app := &applicaion{
+		config: config{auth: authConfig{token: tokenConfig{
+			exp:        time.Hour,
+			refreshExp: time.Hour,
+			iss:        "gosocial",
+		}}},
+		store: store.Storage{
+			Users:      users,
+			OIDCStates: states,
+			Sessions:   &memorySessionStore{},
+			MFA:        &memoryMFAStore{},
+		},
+		logger:            zap.NewNop().Sugar(),
+		authenticator:     auth.NewJWTAuthenticator("secret", "gosocial", "gosocial"),
+		identityProviders: map[string]oidc.IdentityProvider{provider.Name(): provider},
+	}
+
+	r := chi.NewRouter()
+	r.Get("/oidc/{provider}", app.oidcAuthorizeHandler)
+	r.Post("/oidc/{provider}/callback", app.oidcCallbackHandler)
+
+	return &oidcTest{t: t, router: r, users: users, states: states}
+}
+
+// authorize starts a login and approves it at the stub issuer as the user
+// described by params. It returns the code and state of the redirect.
+func (o *oidcTest) authorize(params url.Values) (string, string) {
+	o.t.Helper()
+
+	rr := httptest.NewRecorder()
+	o.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/oidc/stub", nil))
+	if rr.Code != http.StatusOK {
+		o.t.Fatalf("authorize: status %d: %s", rr.Code, rr.Body)
+	}
+
+	var body struct {
+		Data OIDCAuthorization `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		o.t.Fatal(err)
+	}
+
+	authURL, err := url.Parse(body.Data.AuthorizationURL)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	qs := authURL.Query()
+	for name := range params {
+		qs.Set(name, params.Get(name))
+	}
+	authURL.RawQuery = qs.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL.String())
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		o.t.Fatalf("issuer authorize: status %d", res.StatusCode)
+	}
+
+	redirect, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+
+	return redirect.Query().Get("code"), redirect.Query().Get("state")
+}
+
+func (o *oidcTest) callback(code, state string) *httptest.ResponseRecorder {
+	o.t.Helper()
+
+	payload, err := json.Marshal(OIDCCallbackPayload{Code: code, State: state})
+	if err != nil {
+		o.t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	o.router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/oidc/stub/callback", bytes.NewReader(payload)))
+	return rr
+}
+
+func TestOIDCLoginCreatesThenFindsTheUser(t *testing.T) {
+	o := newOIDCTest(t)
+
+	code, state := o.authorize(url.Values{"sub": {"alice-sub"}, "email": {"alice@example.com"}})
+	if rr := o.callback(code, state); rr.Code != http.StatusCreated {
+		t.Fatalf("first login: status %d: %s", rr.Code, rr.Body)
+	}
+
+	if len(o.users.users) != 1 {
+		t.Fatalf("%d users after the first login, want 1", len(o.users.users))
+	}
+	user := o.users.users[1]
+	if user.Email != "alice@example.com" || user.Username != "alice" || !user.IsActive {
+		t.Fatalf("created user = %+v", user)
+	}
+
+	code, state = o.authorize(url.Values{"sub": {"alice-sub"}, "email": {"alice@example.com"}})
+	if rr := o.callback(code, state); rr.Code != http.StatusCreated {
+		t.Fatalf("second login: status %d: %s", rr.Code, rr.Body)
+	}
+	if len(o.users.users) != 1 {
+		t.Fatalf("%d users after the second login, want 1", len(o.users.users))
+	}
+}
+
+func TestOIDCLoginLinksTheUserWithTheEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	o.users.users[7] = &store.User{ID: 7, Username: "bob", Email: "bob@example.com"}
+
+	code, state := o.authorize(url.Values{"sub": {"bob-sub"}, "email": {"bob@example.com"}})
+	if rr := o.callback(code, state); rr.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body)
+	}
+
+	if len(o.users.users) != 1 {
+		t.Fatalf("%d users, want the existing one only", len(o.users.users))
+	}
+	if userID := o.users.identities["stub|bob-sub"]; userID != 7 {
+		t.Fatalf("identity linked to user %d, want 7", userID)
+	}
+	if !o.users.users[7].IsActive {
+		t.Fatal("the linked user isn't active")
+	}
+}
+
+func TestOIDCLoginRequiresAVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	o.users.users[7] = &store.User{ID: 7, Username: "carol", Email: "carol@example.com"}
+
+	code, state := o.authorize(url.Values{"sub": {"carol-sub"}, "email": {"carol@example.com"}, "email_verified": {"false"}})
+	if rr := o.callback(code, state); rr.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if len(o.users.identities) != 0 {
+		t.Fatal("the identity was linked")
+	}
+}
+
+func TestOIDCCallbackRejections(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper changes the login between the issuer redirect and the callback
+		tamper func(o *oidcTest, code, state string) (string, string)
+	}{
+		{
+			name: "unknown state",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				return code, "unknown"
+			},
+		},
+		{
+			name: "replayed state",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				if rr := o.callback(code, state); rr.Code != http.StatusCreated {
+					o.t.Fatalf("first use: status %d: %s", rr.Code, rr.Body)
+				}
+				return code, state
+			},
+		},
+		{
+			name: "expired state",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				oidcState := o.states.states[state]
+				oidcState.Expiry = time.Now().Add(-time.Second)
+				o.states.states[state] = oidcState
+				return code, state
+			},
+		},
+		{
+			name: "state of another provider",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				oidcState := o.states.states[state]
+				oidcState.Provider = "other"
+				o.states.states[state] = oidcState
+				return code, state
+			},
+		},
+		{
+			name: "wrong pkce verifier",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				oidcState := o.states.states[state]
+				oidcState.CodeVerifier = "not-the-verifier"
+				o.states.states[state] = oidcState
+				return code, state
+			},
+		},
+		{
+			name: "wrong nonce",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				oidcState := o.states.states[state]
+				oidcState.Nonce = "not-the-nonce"
+				o.states.states[state] = oidcState
+				return code, state
+			},
+		},
+		{
+			name: "code of another login",
+			tamper: func(o *oidcTest, code, state string) (string, string) {
+				other, _ := o.authorize(url.Values{"sub": {"mallory-sub"}})
+				return other, state
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t)
+
+			code, state := o.authorize(url.Values{"sub": {"dave-sub"}, "email": {"dave@example.com"}})
+			code, state = tt.tamper(o, code, state)
+
+			if rr := o.callback(code, state); rr.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, want %d: %s", rr.Code, http.StatusUnauthorized, rr.Body)
+			}
+		})
+	}
+}
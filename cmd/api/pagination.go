@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var errCursorWithOffset = errors.New("cursor and offset can't be combined, offset is deprecated")
+
+// readCursor decodes the cursor query parameter, it must have been issued
+// for the list named by scope. It returns nil without a cursor.
+func (app *applicaion) readCursor(r *http.Request, scope string) (*store.Cursor, bool, error) {
+	raw := r.URL.Query().Get("cursor")
+	if raw == "" {
+		return nil, false, nil
+	}
+
+	position, backward, err := app.cursors.Decode(scope, raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &position, backward, nil
+}
+
+// parseCursorQuery reads the limit and cursor query parameters.
+func (app *applicaion) parseCursorQuery(r *http.Request, scope string, defaultLimit int) (store.CursorQuery, error) {
+	q := store.CursorQuery{Limit: defaultLimit}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, fmt.Errorf("invalid limit: %q", limit)
+		}
+		q.Limit = l
+	}
+
+	cursor, backward, err := app.readCursor(r, scope)
+	if err != nil {
+		return q, err
+	}
+	q.Cursor, q.Backward = cursor, backward
+
+	return q, nil
+}
+
+// paginatedResponse writes the page with the cursors of the neighbouring
+// pages, both in the envelope and in the Link header.
+func (app *applicaion) paginatedResponse(w http.ResponseWriter, r *http.Request, scope string, data any, info store.PageInfo) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	env := envelope{Data: data}
+	var links []string
+
+	if info.Next != nil {
+		env.NextCursor = app.cursors.Encode(scope, *info.Next, false)
+		links = append(links, pageLink(r, env.NextCursor, "next"))
+	}
+	if info.Prev != nil {
+		env.PrevCursor = app.cursors.Encode(scope, *info.Prev, true)
+		links = append(links, pageLink(r, env.PrevCursor, "prev"))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return writeJSON(w, http.StatusOK, &env)
+}
+
+// pageLink is the request URL moved to the cursor, other filters are kept.
+func pageLink(r *http.Request, cursor, rel string) string {
+	u := *r.URL
+	q := u.Query()
+	q.Del("offset")
+	q.Set("cursor", cursor)
+	u.RawQuery = q.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+}
+
+// Ranked lists page by index instead: their cursors hold the time the list
+// was ranked as of and the index a page starts at, so every page is taken
+// from the same ranking.
+
+// readRankedCursor returns the time the list is ranked as of and the index
+// the page of limit rows starts at, the top of the list as of now without a
+// cursor.
+func (app *applicaion) readRankedCursor(r *http.Request, scope string, limit int) (time.Time, int, error) {
+	position, backward, err := app.readCursor(r, scope)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if position == nil {
+		return time.Now(), 0, nil
+	}
+
+	start := int(position.ID)
+	if backward {
+		start = max(start-limit, 0)
+	}
+
+	return position.CreatedAt, start, nil
+}
+
+// rankedPageInfo returns the cursors of the neighbours of the page from
+// start to end.
+func rankedPageInfo(asOf time.Time, start, end int, more bool) store.PageInfo {
+	var info store.PageInfo
+	if more {
+		info.Next = &store.Cursor{CreatedAt: asOf, ID: int64(end)}
+	}
+	if start > 0 {
+		info.Prev = &store.Cursor{CreatedAt: asOf, ID: int64(start)}
+	}
+	return info
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mayankpatidar275/go-social/internal/lockout"
+	"github.com/mayankpatidar275/go-social/internal/mailer"
+	"github.com/mayankpatidar275/go-social/internal/password"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+const (
+	// passwordResetTimeout bounds the lookup and the email of a reset request
+	passwordResetTimeout = time.Second * 30
+	// passwordResetKeyPrefix keeps the reset requests of an IP apart from its logins
+	passwordResetKeyPrefix = "password-reset:"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a one-time password reset link. The response is the same whether the email exists or not
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error	"Too many requests from the IP"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *applicaion) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ipKey := passwordResetKeyPrefix + lockout.IPKey(r.RemoteAddr)
+
+	retryAfter, err := app.lockout.Check(ctx, ipKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	if _, err := app.lockout.Fail(ctx, ipKey, app.config.lockout.MaxIPAttempts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Note: the lookup and the email happen after the response, its timing or
+	// a failing mailer would tell which emails have an account otherwise
+	go app.sendPasswordReset(payload.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordReset emails a reset link to the user owning the email, if any.
+// A user gets at most one reset per cooldown.
+func (app *applicaion) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+	defer cancel()
+
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if err != store.ErrNotFound {
+			app.logger.Errorw("error finding the user of a password reset", "error", err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	// hash the token for storage but keep the plain token for email
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err = app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken, app.config.mail.resetExp, app.config.mail.resetCooldown)
+	if err != nil {
+		if err != store.ErrThrottled {
+			app.logger.Errorw("error creating password reset", "user", user.ID, "error", err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username string
+		ResetURL string
+		Expiry   string
+	}{
+		Username: user.Username,
+		ResetURL: fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		Expiry:   app.config.mail.resetExp.String(),
+	}
+
+	status, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending password reset email", "user", user.ID, "error", err)
+		return
+	}
+
+	app.logger.Infow("Email sent", "status code", status)
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using the emailed token and logs the user out everywhere
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *applicaion) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByPasswordReset(ctx, payload.Token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !app.checkPasswordPolicy(w, r, payload.Password, user.Username, user.Email) {
+		return
+	}
+
+	user, err = app.store.Users.ResetPassword(ctx, payload.Token, payload.Password)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// whoever knew the old password should not stay logged in
+	if err := app.revokeAllTokens(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the owner proved access to the mailbox, let them log in right away
+	if err := app.lockout.Reset(ctx, lockout.AccountKey(user.Email)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=72"`
+	NewPassword     string `json:"new_password" validate:"required,max=72"`
+}
+
+// changePasswordHandler godoc
+//
+//	@Summary		Changes the password
+//	@Description	Changes the password of the current user. Every other session is logged out, the response holds new tokens for this one
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	TokenPair
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/password [put]
+func (app *applicaion) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	// the cached user has no password hash
+	user, err := app.store.Users.GetByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := user.Password.Compare(payload.CurrentPassword); err != nil {
+		app.badRequestResponse(w, r, errIncorrectPassword)
+		return
+	}
+
+	if !app.checkPasswordPolicy(w, r, payload.NewPassword, user.Username, user.Email) {
+		return
+	}
+
+	if err := user.Password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.revokeAllTokens(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the session making the change was revoked too, keep it going with a new one
+	tokens, err := app.issueTokens(r, user, hasAMR(getClaimsFromCtx(r), "otp"))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkPasswordPolicy answers with the broken rules when the password does not
+// meet the policy.
+func (app *applicaion) checkPasswordPolicy(w http.ResponseWriter, r *http.Request, plain string, personal ...string) bool {
+	err := app.config.passwordPolicy.Validate(plain, personal...)
+	if err == nil {
+		return true
+	}
+
+	if policyErr, ok := err.(*password.PolicyError); ok {
+		app.passwordPolicyResponse(w, r, policyErr)
+	} else {
+		app.internalServerError(w, r, err)
+	}
+
+	return false
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/lockout"
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryResetUserStore adds the password resets, and their cooldown, to
+// memoryUserStore.
+type memoryResetUserStore struct {
+	*memoryUserStore
+	resets map[int64]time.Time
+}
+
+func (s *memoryResetUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	return s.GetByEmailIncludingDeleted(ctx, email)
+}
+
+func (s *memoryResetUserStore) CreatePasswordReset(_ context.Context, userID int64, _ string, _, cooldown time.Duration) error {
+	if last, ok := s.resets[userID]; ok && time.Since(last) < cooldown {
+		return store.ErrThrottled
+	}
+	s.resets[userID] = time.Now()
+	return nil
+}
+
+// memoryLoginAttemptStore follows the failure counting of store.LoginAttemptStore.
+type memoryLoginAttemptStore struct {
+	attempts map[string]*store.LoginAttempt
+}
+
+func (s *memoryLoginAttemptStore) Get(_ context.Context, key string) (*store.LoginAttempt, error) {
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) RecordFailure(_ context.Context, key string, window time.Duration) (*store.LoginAttempt, error) {
+	attempt, ok := s.attempts[key]
+	if !ok || time.Since(attempt.LastFailure) > window {
+		attempt = &store.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailure = time.Now()
+	return attempt, nil
+}
+
+func (s *memoryLoginAttemptStore) Lock(_ context.Context, key string, until time.Time) error {
+	attempt := s.attempts[key]
+	attempt.LockedUntil = until
+	attempt.Lockouts++
+	attempt.Failures = 0
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	delete(s.attempts, key)
+	return nil
+}
+
+// recordingMailer reports every email on sent and answers with err.
+type recordingMailer struct {
+	sent chan string
+	err  error
+}
+
+func (m *recordingMailer) Send(_, _, email string, _ any, _ bool) (int, error) {
+	m.sent <- email
+	if m.err != nil {
+		return 0, m.err
+	}
+	return http.StatusAccepted, nil
+}
+
+func newPasswordResetTestApp(t *testing.T, mailErr error) (*applicaion, *recordingMailer) {
+	t.Helper()
+
+	users := &memoryResetUserStore{
+		memoryUserStore: &memoryUserStore{users: map[int64]*store.User{
+			1: {ID: 1, Username: "alice", Email: "alice@example.com"},
+		}},
+		resets: make(map[int64]time.Time),
+	}
+	mailer := &recordingMailer{sent: make(chan string, 10), err: mailErr}
+
+	lockoutCfg := lockout.Config{
+		MaxIPAttempts: 3,
+		Window:        time.Hour,
+		BaseDuration:  time.Minute,
+		MaxDuration:   time.Hour,
+		Enabled:       true,
+	}
+
+	app := &applicaion{
+		config: config{
+			mail:    mailConfig{resetExp: time.Hour, resetCooldown: time.Hour},
+			lockout: lockoutCfg,
+		},
+		store:   store.Storage{Users: users},
+		logger:  zap.NewNop().Sugar(),
+		mailer:  mailer,
+		lockout: lockout.NewTracker(&memoryLoginAttemptStore{attempts: make(map[string]*store.LoginAttempt)}, lockoutCfg),
+	}
+
+	return app, mailer
+}
+
+func forgotPassword(t *testing.T, app *applicaion, email string) int {
+	t.Helper()
+
+	body, err := json.Marshal(ForgotPasswordPayload{Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	app.forgotPasswordHandler(rr, httptest.NewRequest(http.MethodPost, "/authentication/password/forgot", bytes.NewReader(body)))
+	return rr.Code
+}
+
+// expectMail waits for the email the handler sends after answering.
+func expectMail(t *testing.T, mailer *recordingMailer, want string) {
+	t.Helper()
+
+	select {
+	case email := <-mailer.sent:
+		if email != want {
+			t.Fatalf("mailed %s, want %s", email, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no email was sent to %s", want)
+	}
+}
+
+func expectNoMail(t *testing.T, mailer *recordingMailer) {
+	t.Helper()
+
+	select {
+	case email := <-mailer.sent:
+		t.Fatalf("mailed %s, want no email", email)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestForgotPasswordAnswersTheSameForEveryEmail(t *testing.T) {
+	app, mailer := newPasswordResetTestApp(t, errors.New("mailer is down"))
+
+	if status := forgotPassword(t, app, "nobody@example.com"); status != http.StatusAccepted {
+		t.Fatalf("unknown email: status %d, want %d", status, http.StatusAccepted)
+	}
+	expectNoMail(t, mailer)
+
+	// the failing mailer doesn't show in the response
+	if status := forgotPassword(t, app, "alice@example.com"); status != http.StatusAccepted {
+		t.Fatalf("known email: status %d, want %d", status, http.StatusAccepted)
+	}
+	expectMail(t, mailer, "alice@example.com")
+}
+
+func TestForgotPasswordCooldownPerUser(t *testing.T) {
+	app, mailer := newPasswordResetTestApp(t, nil)
+
+	if status := forgotPassword(t, app, "alice@example.com"); status != http.StatusAccepted {
+		t.Fatalf("first request: status %d", status)
+	}
+	expectMail(t, mailer, "alice@example.com")
+
+	if status := forgotPassword(t, app, "alice@example.com"); status != http.StatusAccepted {
+		t.Fatalf("second request: status %d, want %d", status, http.StatusAccepted)
+	}
+	expectNoMail(t, mailer)
+}
+
+func TestForgotPasswordThrottlesTheIP(t *testing.T) {
+	app, _ := newPasswordResetTestApp(t, nil)
+
+	for i := 0; i < app.config.lockout.MaxIPAttempts; i++ {
+		if status := forgotPassword(t, app, "nobody@example.com"); status != http.StatusAccepted {
+			t.Fatalf("request %d: status %d, want %d", i+1, status, http.StatusAccepted)
+		}
+	}
+
+	if status := forgotPassword(t, app, "alice@example.com"); status != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
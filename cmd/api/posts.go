@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -63,6 +64,8 @@ func (app *applicaion) createPostHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	go app.fanOutPost(user, post.ID)
+
 	// Note: It might look like we are sending partial data of post.
 	// But note that the post has been updated in the Create method. It is a pointer.
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
@@ -88,7 +91,27 @@ func (app *applicaion) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	author, err := app.getUser(r.Context(), post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	viewer := getUserFromCtx(r)
+
+	allowed, err := app.canSeePostsOf(r.Context(), viewer, author)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Note: same answer as a missing post, it would tell the post exists otherwise
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, viewer.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -102,6 +125,67 @@ func (app *applicaion) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getCommentsHandler godoc
+//
+//	@Summary		Lists the comments of a post
+//	@Description	Lists the comments of a post, newest first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *applicaion) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	scope := fmt.Sprintf("comments:%d", post.ID)
+
+	q, err := app.parseCursorQuery(r, scope, 20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	viewer := getUserFromCtx(r)
+
+	author, err := app.getUser(ctx, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	allowed, err := app.canSeePostsOf(ctx, viewer, author)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	comments, info, err := app.store.Comments.List(ctx, post.ID, viewer.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, scope, comments, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // DeletePost godoc
 //
 //	@Summary		Deletes a post
@@ -125,6 +209,8 @@ func (app *applicaion) deletePostHandler(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
+	app.removePostFromTimelines(ctx, id)
+
 	if err := app.store.Posts.Delete(ctx, id); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store/cache"
+)
+
+// getPublicPostsHandler godoc
+//
+//	@Summary		Lists the latest public posts
+//	@Description	Lists the posts of public users, newest first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/posts [get]
+func (app *applicaion) getPublicPostsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listPublicPosts(w, r, "posts", "")
+}
+
+// getTagPostsHandler godoc
+//
+//	@Summary		Lists the latest public posts of a tag
+//	@Description	Lists the posts of public users having the tag, newest first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			posts
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (app *applicaion) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	if err := Validate.Var(tag, "required,max=50"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	app.listPublicPosts(w, r, fmt.Sprintf("tags:%s:posts", tag), tag)
+}
+
+func (app *applicaion) listPublicPosts(w http.ResponseWriter, r *http.Request, scope, tag string) {
+	q, err := app.parseCursorQuery(r, scope, 20)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, info, err := app.store.Posts.GetPublic(r.Context(), tag, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedResponse(w, r, scope, posts, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getTrendingTagsHandler godoc
+//
+//	@Summary		Lists the trending tags
+//	@Description	Lists the tags of the public posts of the sliding window, used by the most authors first. Pass next_cursor or prev_cursor as cursor to get the neighbouring pages
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{array}		store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *applicaion) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	scope := "tags:trending"
+
+	q, err := app.parseCursorQuery(r, scope, 10)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	asOf, start, err := app.readRankedCursor(r, scope, q.Limit)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.store.Posts.GetTrendingTags(r.Context(), asOf.Add(-app.config.trendingWindow), asOf, start, q.Limit+1)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	more := len(tags) > q.Limit
+	if more {
+		tags = tags[:q.Limit]
+	}
+
+	info := rankedPageInfo(asOf, start, start+len(tags), more)
+
+	if err := app.paginatedResponse(w, r, scope, tags, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// publicCacheMiddleware lets clients and proxies cache the successful
+// responses of the public lists for a few seconds, they are also cached in
+// redis when it is enabled.
+func (app *applicaion) publicCacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl := app.config.publicCacheTTL
+		cacheControl := fmt.Sprintf("public, max-age=%d", int(ttl.Seconds()))
+
+		if !app.config.redisCfg.enabled {
+			next.ServeHTTP(&cachingWriter{ResponseWriter: w, cacheControl: cacheControl}, r)
+			return
+		}
+
+		ctx := r.Context()
+		// Note: Encode sorts the parameters, the same query always has the same key
+		key := r.URL.Path + "?" + r.URL.Query().Encode()
+
+		cached, err := app.cacheStorage.Responses.Get(ctx, key)
+		if err != nil {
+			app.logger.Errorw("error reading cached response", "key", key, "error", err)
+		}
+		if cached != nil {
+			for name, values := range cached.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Cache-Control", cacheControl)
+			w.WriteHeader(http.StatusOK)
+			w.Write(cached.Body)
+			return
+		}
+
+		cw := &cachingWriter{ResponseWriter: w, cacheControl: cacheControl, record: true}
+		next.ServeHTTP(cw, r)
+
+		if cw.status != http.StatusOK {
+			return
+		}
+
+		response := &cache.Response{
+			Header: http.Header{
+				"Content-Type": w.Header().Values("Content-Type"),
+				"Link":         w.Header().Values("Link"),
+			},
+			Body: cw.body.Bytes(),
+		}
+		if err := app.cacheStorage.Responses.Set(ctx, key, response, ttl); err != nil {
+			app.logger.Errorw("error caching response", "key", key, "error", err)
+		}
+	})
+}
+
+// cachingWriter marks the successful responses cacheable and records them
+// when record is set.
+type cachingWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	record       bool
+	status       int
+	body         bytes.Buffer
+}
+
+func (cw *cachingWriter) WriteHeader(status int) {
+	cw.status = status
+	if status == http.StatusOK {
+		cw.Header().Set("Cache-Control", cw.cacheControl)
+	} else {
+		cw.Header().Set("Cache-Control", "no-store")
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cachingWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.record {
+		cw.body.Write(b)
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
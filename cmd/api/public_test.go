@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store/cache"
+	"go.uber.org/zap"
+)
+
+type memoryResponseStore struct {
+	responses map[string]*cache.Response
+}
+
+func (s *memoryResponseStore) Get(_ context.Context, key string) (*cache.Response, error) {
+	return s.responses[key], nil
+}
+
+func (s *memoryResponseStore) Set(_ context.Context, key string, response *cache.Response, _ time.Duration) error {
+	s.responses[key] = response
+	return nil
+}
+
+func newPublicCacheTestApp(redisEnabled bool) (*applicaion, *memoryResponseStore) {
+	responses := &memoryResponseStore{responses: make(map[string]*cache.Response)}
+
+	app := &applicaion{
+		config: config{
+			publicCacheTTL: 30 * time.Second,
+			redisCfg:       redisConfig{enabled: redisEnabled},
+		},
+		cacheStorage: cache.Storage{Responses: responses},
+		logger:       zap.NewNop().Sugar(),
+	}
+
+	return app, responses
+}
+
+func TestPublicCacheMiddleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		handler          func(app *applicaion) http.HandlerFunc
+		wantStatus       int
+		wantCacheControl string
+		wantCached       bool
+	}{
+		{
+			name: "success",
+			handler: func(app *applicaion) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					app.jsonResponse(w, http.StatusOK, []string{"post"})
+				}
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=30",
+			wantCached:       true,
+		},
+		{
+			name: "implicit success",
+			handler: func(app *applicaion) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("[]"))
+				}
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=30",
+			wantCached:       true,
+		},
+		{
+			name: "bad request",
+			handler: func(app *applicaion) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					app.badRequestResponse(w, r, errors.New("invalid cursor"))
+				}
+			},
+			wantStatus:       http.StatusBadRequest,
+			wantCacheControl: "no-store",
+		},
+		{
+			name: "server error",
+			handler: func(app *applicaion) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					app.internalServerError(w, r, errors.New("database is down"))
+				}
+			},
+			wantStatus:       http.StatusInternalServerError,
+			wantCacheControl: "no-store",
+		},
+		{
+			name: "empty error",
+			handler: func(app *applicaion) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			},
+			wantStatus:       http.StatusServiceUnavailable,
+			wantCacheControl: "no-store",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, redisEnabled := range []bool{false, true} {
+			name := tt.name
+			if redisEnabled {
+				name += " with redis"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				app, responses := newPublicCacheTestApp(redisEnabled)
+				handler := app.publicCacheMiddleware(tt.handler(app))
+
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/posts?limit=5", nil))
+
+				if rr.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+				}
+				if got := rr.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+					t.Errorf("cache control = %q, want %q", got, tt.wantCacheControl)
+				}
+
+				wantCached := tt.wantCached && redisEnabled
+				if cached := len(responses.responses) > 0; cached != wantCached {
+					t.Errorf("cached = %v, want %v", cached, wantCached)
+				}
+			})
+		}
+	}
+}
+
+func TestPublicCacheMiddlewareServesCachedResponses(t *testing.T) {
+	app, _ := newPublicCacheTestApp(true)
+
+	calls := 0
+	handler := app.publicCacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		app.jsonResponse(w, http.StatusOK, []string{"post"})
+	}))
+
+	var bodies []string
+	// the parameters are sorted, both requests have the same key
+	for _, target := range []string{"/v1/posts?limit=5&cursor=a", "/v1/posts?cursor=a&limit=5"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+		}
+		if got := rr.Header().Get("Cache-Control"); got != "public, max-age=30" {
+			t.Errorf("cache control = %q", got)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("content type = %q, want application/json", got)
+		}
+		bodies = append(bodies, rr.Body.String())
+	}
+
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("cached body = %q, want %q", bodies[1], bodies[0])
+	}
+}
+
+func TestPublicCacheMiddlewareDoesntServeCachedErrors(t *testing.T) {
+	app, _ := newPublicCacheTestApp(true)
+
+	calls := 0
+	handler := app.publicCacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		app.internalServerError(w, r, errors.New("database is down"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/posts", nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusInternalServerError)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
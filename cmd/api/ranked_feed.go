@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/ranking"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var errRankedFilters = errors.New("the ranked feed can't be sorted, filtered or paged by offset")
+
+const (
+	// rankedWindow is how old the posts of the ranked feed can be
+	rankedWindow = time.Hour * 24 * 7
+	// rankedCandidates is the number of candidates taken from the followed
+	// users and from the popular posts
+	rankedCandidates = 250
+	// tagAffinityWindow is how far back the engagement of the viewer with
+	// tags is looked at
+	tagAffinityWindow = time.Hour * 24 * 90
+)
+
+// RankedPost is a post of the ranked feed, Debug explains its score to admins.
+type RankedPost struct {
+	store.RankingCandidate
+	Debug *ranking.Explanation `json:"debug,omitempty"`
+}
+
+// rankedFeed writes the page of the ranked feed. Every page is ranked as of
+// the time of the first one from the comments and likes up to then, only
+// deletions can move posts between pages.
+func (app *applicaion) rankedFeed(w http.ResponseWriter, r *http.Request, user *store.User, fq store.PaginatedFeedQuery) {
+	qs := r.URL.Query()
+	if qs.Has("offset") || qs.Has("sort") || len(fq.Tags) > 0 || fq.Search != "" || fq.Since != nil || fq.Until != nil {
+		app.badRequestResponse(w, r, errRankedFilters)
+		return
+	}
+
+	ctx := r.Context()
+
+	debug := qs.Get("debug") == "true"
+	if debug {
+		allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	scope := fmt.Sprintf("feed:%d:ranked", user.ID)
+
+	asOf, start, err := app.readRankedCursor(r, scope, fq.Limit)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	candidates, err := app.store.Posts.GetRankingCandidates(ctx, user.ID, asOf.Add(-rankedWindow), asOf, rankedCandidates)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tags, err := app.store.Posts.GetTagAffinity(ctx, user.ID, asOf.Add(-tagAffinityWindow), asOf)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	toRank := make([]ranking.Candidate, len(candidates))
+	for i, c := range candidates {
+		toRank[i] = ranking.Candidate{
+			PostID:       c.ID,
+			CreatedAt:    c.CreatedAtTime,
+			Tags:         c.Tags,
+			Comments:     c.CommentCount,
+			Likes:        c.LikeCount,
+			Interactions: c.Interactions,
+		}
+	}
+
+	ranked := app.ranker.Rank(toRank, ranking.Viewer{Now: asOf, Tags: tags})
+
+	end := min(start+fq.Limit, len(ranked))
+	start = min(start, end)
+
+	page := make([]RankedPost, 0, end-start)
+	for _, rk := range ranked[start:end] {
+		post := RankedPost{RankingCandidate: candidates[rk.Index]}
+		if debug {
+			post.Debug = &rk.Explanation
+		}
+		page = append(page, post)
+	}
+
+	info := rankedPageInfo(asOf, start, end, end < len(ranked))
+
+	if err := app.paginatedResponse(w, r, scope, page, info); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
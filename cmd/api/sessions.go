@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+const maxUserAgentLength = 512
+
+type SessionResponse struct {
+	store.Session
+	// Current is true for the session making the request
+	Current bool `json:"current"`
+}
+
+// getSessionsHandler godoc
+//
+//	@Summary		Lists sessions
+//	@Description	Lists the devices the current user is logged in on
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		SessionResponse
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *applicaion) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	currentSID, _ := getClaimsFromCtx(r)["sid"].(string)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.ID == currentSID,
+		})
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteSessionHandler godoc
+//
+//	@Summary		Ends a session
+//	@Description	Logs the user out of one device, its access and refresh tokens stop working right away
+//	@Tags			users
+//	@Produce		json
+//	@Param			sessionID	path		string	true	"Session ID"
+//	@Success		204			{string}	string	"Session ended"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *applicaion) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := app.store.Sessions.Delete(r.Context(), user.ID, sessionID.String()); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the address set by the RealIP middleware without the port.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	// do not leave half a multi-byte character behind
+	return strings.ToValidUTF8(s[:n], "")
+}
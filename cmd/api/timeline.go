@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"github.com/mayankpatidar275/go-social/internal/store/cache"
+)
+
+// The home timelines hold every post created during the retention period by
+// the user and the users they follow, except for the authors with more than
+// fanOutMaxFollowers followers: their posts are merged in when the timeline
+// is read. The newest entries are cached in redis. The feed is read with the
+// query over the followers whenever the timeline can't serve the whole page.
+
+type timelineConfig struct {
+	// enabled serves the feed from the timelines, they are maintained either
+	// way so it can be switched on at any time
+	enabled            bool
+	fanOutMaxFollowers int
+	// retention must not exceed the 30 days materialised by the migration
+	retention time.Duration
+	// verifyPercent of the pages served from the timelines are compared to
+	// the query over the followers
+	verifyPercent int
+}
+
+const timelineTimeout = time.Second * 30
+
+// timelineMaxReads bounds the reads filling a page of the timeline when the
+// user may not see many of its posts, the query over the followers serves
+// the page past it.
+const timelineMaxReads = 3
+
+// fanOutPost pushes the post to the timelines of its author and their
+// followers. The request context ends with the response, so it runs in the
+// background.
+func (app *applicaion) fanOutPost(author *store.User, postID int64) {
+	if author.FollowersCount > app.config.timeline.fanOutMaxFollowers {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timelineTimeout)
+	defer cancel()
+
+	userIDs, position, err := app.store.Timelines.FanOut(ctx, postID)
+	if err != nil {
+		app.logger.Errorw("error fanning out post", "post", postID, "error", err)
+		return
+	}
+
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Timelines.Push(ctx, userIDs, position); err != nil {
+		app.logger.Errorw("error pushing post to cached timelines", "post", postID, "error", err)
+	}
+}
+
+// removePostFromTimelines must run before the post is deleted. The feed
+// skips the posts that no longer exist, a failure is only logged.
+func (app *applicaion) removePostFromTimelines(ctx context.Context, postID int64) {
+	userIDs, position, err := app.store.Timelines.RemovePost(ctx, postID)
+	if err != nil {
+		app.logger.Errorw("error removing post from timelines", "post", postID, "error", err)
+		return
+	}
+
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Timelines.Remove(ctx, userIDs, position); err != nil {
+		app.logger.Errorw("error removing post from cached timelines", "post", postID, "error", err)
+	}
+}
+
+// backfillTimeline pushes the recent posts of the author to the timeline of
+// their new follower.
+func (app *applicaion) backfillTimeline(ctx context.Context, userID int64, author *store.User) {
+	if author.FollowersCount > app.config.timeline.fanOutMaxFollowers {
+		return
+	}
+
+	since := time.Now().Add(-app.config.timeline.retention)
+	if err := app.store.Timelines.Backfill(ctx, userID, author.ID, since); err != nil {
+		app.logger.Errorw("error backfilling timeline", "user", userID, "author", author.ID, "error", err)
+	}
+
+	app.invalidateTimeline(ctx, userID)
+}
+
+// purgeTimeline takes the posts of the author out of the timeline of the
+// user who no longer follows or muted them. The feed leaves those posts out
+// anyway, a failure is only logged.
+func (app *applicaion) purgeTimeline(ctx context.Context, userID, authorID int64) {
+	if err := app.store.Timelines.RemoveAuthor(ctx, userID, authorID); err != nil {
+		app.logger.Errorw("error purging timeline", "user", userID, "author", authorID, "error", err)
+	}
+
+	app.invalidateTimeline(ctx, userID)
+}
+
+// restoreTimeline brings the posts of the author the user unmuted back to
+// their timeline if they still follow them.
+func (app *applicaion) restoreTimeline(ctx context.Context, userID, authorID int64) {
+	following, err := app.store.Followers.IsFollowing(ctx, userID, authorID)
+	if err != nil {
+		app.logger.Errorw("error restoring timeline", "user", userID, "author", authorID, "error", err)
+		return
+	}
+	if !following {
+		return
+	}
+
+	author, err := app.getUser(ctx, authorID)
+	if err != nil {
+		app.logger.Errorw("error restoring timeline", "user", userID, "author", authorID, "error", err)
+		return
+	}
+
+	app.backfillTimeline(ctx, userID, author)
+}
+
+func (app *applicaion) invalidateTimeline(ctx context.Context, userID int64) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Timelines.Delete(ctx, userID); err != nil {
+		app.logger.Errorw("error deleting cached timeline", "user", userID, "error", err)
+	}
+}
+
+// canReadTimeline tells whether the page of the feed can be served from the
+// timelines: they only hold the newest first order without filters.
+func (app *applicaion) canReadTimeline(fq store.PaginatedFeedQuery) bool {
+	return app.config.timeline.enabled &&
+		fq.Sort == "desc" && !fq.Backward && fq.Offset == 0 &&
+		len(fq.Tags) == 0 && fq.Search == "" &&
+		fq.Since == nil && fq.Until == nil
+}
+
+// readTimeline reads the page of the feed from the timeline of the user. ok
+// is false when the timeline doesn't hold the whole page.
+func (app *applicaion) readTimeline(ctx context.Context, user *store.User, fq store.PaginatedFeedQuery) ([]store.PostWithMetaData, store.PageInfo, bool, error) {
+	// one more post tells whether there is a next page
+	n := fq.Limit + 1
+
+	// older entries may have been trimmed already
+	horizon := time.Now().Add(-app.config.timeline.retention)
+
+	var (
+		feed      []store.PostWithMetaData
+		positions []store.Cursor
+	)
+	after := fq.Cursor
+	// the posts the user may not see are dropped from the entries, the
+	// following entries fill the page
+	for reads := 0; len(feed) < n; reads++ {
+		if reads == timelineMaxReads {
+			return nil, store.PageInfo{}, false, nil
+		}
+
+		entries, err := app.timelineEntries(ctx, user.ID, after, n)
+		if err != nil {
+			return nil, store.PageInfo{}, false, err
+		}
+
+		fanOutOnRead, err := app.store.Posts.GetFanOutOnReadPositions(ctx, user.ID, app.config.timeline.fanOutMaxFollowers, after, n)
+		if err != nil {
+			return nil, store.PageInfo{}, false, err
+		}
+
+		entries = mergeTimeline(entries, fanOutOnRead, n)
+		entries = slices.DeleteFunc(entries, func(c store.Cursor) bool {
+			return c.CreatedAt.Before(horizon)
+		})
+		if len(entries) == 0 {
+			return nil, store.PageInfo{}, false, nil
+		}
+
+		posts, postPositions, err := app.store.Posts.GetTimelinePosts(ctx, user.ID, entries)
+		if err != nil {
+			return nil, store.PageInfo{}, false, err
+		}
+
+		feed = append(feed, posts...)
+		positions = append(positions, postPositions...)
+		after = &entries[len(entries)-1]
+
+		// the timeline ran out before the page did
+		if len(feed) < n && len(entries) < n {
+			return nil, store.PageInfo{}, false, nil
+		}
+	}
+
+	feed, positions = feed[:fq.Limit], positions[:fq.Limit]
+
+	info := store.PageInfo{Next: &positions[len(positions)-1]}
+	if fq.Cursor != nil {
+		info.Prev = &positions[0]
+	}
+
+	return feed, info, true, nil
+}
+
+// timelineEntries reads the entries from redis when it holds them all,
+// filling it on a miss of the first page.
+func (app *applicaion) timelineEntries(ctx context.Context, userID int64, after *store.Cursor, n int) ([]store.Cursor, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Timelines.Get(ctx, userID, after, n)
+	}
+
+	entries, found, err := app.cacheStorage.Timelines.Get(ctx, userID, after, n)
+	if err != nil {
+		app.logger.Errorw("error reading cached timeline", "user", userID, "error", err)
+		return app.store.Timelines.Get(ctx, userID, after, n)
+	}
+	if found && len(entries) == n {
+		return entries, nil
+	}
+	if found || after != nil {
+		return app.store.Timelines.Get(ctx, userID, after, n)
+	}
+
+	entries, err = app.store.Timelines.Get(ctx, userID, nil, cache.TimelineSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cacheStorage.Timelines.Set(ctx, userID, entries); err != nil {
+		app.logger.Errorw("error caching timeline", "user", userID, "error", err)
+	}
+
+	return entries[:min(n, len(entries))], nil
+}
+
+// mergeTimeline merges the entries of b into a, both newest first, keeping
+// the first n. A post in both is kept once.
+func mergeTimeline(a, b []store.Cursor, n int) []store.Cursor {
+	merged := make([]store.Cursor, 0, min(n, len(a)+len(b)))
+	seen := make(map[int64]bool, cap(merged))
+	for len(merged) < n && (len(a) > 0 || len(b) > 0) {
+		var next store.Cursor
+		switch {
+		case len(b) == 0 || (len(a) > 0 && newerThan(a[0], b[0])):
+			next, a = a[0], a[1:]
+		default:
+			next, b = b[0], b[1:]
+		}
+
+		// posts of authors who crossed the threshold can be in both
+		if seen[next.ID] {
+			continue
+		}
+		seen[next.ID] = true
+		merged = append(merged, next)
+	}
+
+	return merged
+}
+
+func newerThan(a, b store.Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// verifyTimeline compares a sample of the pages served from the timelines to
+// the query over the followers, a mismatch is logged. The page ends at the
+// position last, the query fills its page with the posts that follow.
+func (app *applicaion) verifyTimeline(userID int64, fq store.PaginatedFeedQuery, feed []store.PostWithMetaData, last *store.Cursor) {
+	if rand.Intn(100) >= app.config.timeline.verifyPercent {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timelineTimeout)
+	defer cancel()
+
+	want, _, err := app.store.Posts.GetUserFeed(ctx, userID, fq)
+	if err != nil {
+		app.logger.Errorw("error verifying timeline", "user", userID, "error", err)
+		return
+	}
+
+	for i, p := range want {
+		createdAt, err := time.Parse(time.RFC3339Nano, p.CreatedAt)
+		if err != nil {
+			app.logger.Errorw("error verifying timeline", "user", userID, "error", err)
+			return
+		}
+		if last != nil && newerThan(*last, store.Cursor{CreatedAt: createdAt, ID: p.ID}) {
+			want = want[:i]
+			break
+		}
+	}
+
+	got, expected := postIDs(feed), postIDs(want)
+	if !slices.Equal(got, expected) {
+		app.logger.Warnw("timeline differs from the feed query", "user", userID, "timeline", got, "query", expected)
+	}
+}
+
+func postIDs(feed []store.PostWithMetaData) []int64 {
+	ids := make([]int64, len(feed))
+	for i, p := range feed {
+		ids[i] = p.ID
+	}
+	return ids
+}
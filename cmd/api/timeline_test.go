@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+// memoryTimelineStore holds the timeline of a single user, newest first.
+type memoryTimelineStore struct {
+	*store.TimelineStore
+	entries []store.Cursor
+}
+
+func (s *memoryTimelineStore) Get(_ context.Context, _ int64, after *store.Cursor, limit int) ([]store.Cursor, error) {
+	entries := s.entries
+	if after != nil {
+		i := slices.IndexFunc(entries, func(c store.Cursor) bool { return newerThan(*after, c) })
+		if i < 0 {
+			return []store.Cursor{}, nil
+		}
+		entries = entries[i:]
+	}
+	return entries[:min(limit, len(entries))], nil
+}
+
+// memoryTimelinePostStore leaves the hidden posts out of the timeline, like
+// the blocked and muted authors.
+type memoryTimelinePostStore struct {
+	*store.PostStore
+	hidden map[int64]bool
+}
+
+func (s *memoryTimelinePostStore) GetFanOutOnReadPositions(context.Context, int64, int, *store.Cursor, int) ([]store.Cursor, error) {
+	return nil, nil
+}
+
+func (s *memoryTimelinePostStore) GetTimelinePosts(_ context.Context, _ int64, entries []store.Cursor) ([]store.PostWithMetaData, []store.Cursor, error) {
+	feed := []store.PostWithMetaData{}
+	var positions []store.Cursor
+	for _, e := range entries {
+		if s.hidden[e.ID] {
+			continue
+		}
+		feed = append(feed, store.PostWithMetaData{Post: store.Post{ID: e.ID}})
+		positions = append(positions, e)
+	}
+	return feed, positions, nil
+}
+
+func TestMergeTimeline(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// at returns the entry of post id created minutes after base
+	at := func(id int64, minutes int) store.Cursor {
+		return store.Cursor{CreatedAt: base.Add(time.Duration(minutes) * time.Minute), ID: id}
+	}
+
+	tests := []struct {
+		name string
+		a, b []store.Cursor
+		n    int
+		want []int64
+	}{
+		{name: "both empty", n: 5, want: []int64{}},
+		{name: "only fan out on write", a: []store.Cursor{at(2, 2), at(1, 1)}, n: 5, want: []int64{2, 1}},
+		{name: "only fan out on read", b: []store.Cursor{at(2, 2), at(1, 1)}, n: 5, want: []int64{2, 1}},
+		{
+			name: "interleaved",
+			a:    []store.Cursor{at(5, 5), at(3, 3), at(1, 1)},
+			b:    []store.Cursor{at(4, 4), at(2, 2)},
+			n:    10,
+			want: []int64{5, 4, 3, 2, 1},
+		},
+		{
+			name: "in both",
+			a:    []store.Cursor{at(4, 4), at(3, 3), at(1, 1)},
+			b:    []store.Cursor{at(4, 4), at(2, 2), at(1, 1)},
+			n:    10,
+			want: []int64{4, 3, 2, 1},
+		},
+		{
+			name: "all in both",
+			a:    []store.Cursor{at(2, 2), at(1, 1)},
+			b:    []store.Cursor{at(2, 2), at(1, 1)},
+			n:    10,
+			want: []int64{2, 1},
+		},
+		{
+			name: "duplicates don't count toward n",
+			a:    []store.Cursor{at(4, 4), at(3, 3), at(2, 2)},
+			b:    []store.Cursor{at(4, 4), at(3, 3), at(1, 1)},
+			n:    3,
+			want: []int64{4, 3, 2},
+		},
+		{
+			name: "same time, highest id first",
+			a:    []store.Cursor{at(3, 1), at(1, 1)},
+			b:    []store.Cursor{at(2, 1)},
+			n:    10,
+			want: []int64{3, 2, 1},
+		},
+		{
+			name: "keeps the first n",
+			a:    []store.Cursor{at(6, 6), at(4, 4), at(2, 2)},
+			b:    []store.Cursor{at(5, 5), at(3, 3), at(1, 1)},
+			n:    4,
+			want: []int64{6, 5, 4, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeTimeline(tt.a, tt.b, tt.n)
+
+			got := make([]int64, len(merged))
+			for i, c := range merged {
+				got[i] = c.ID
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("merged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTimelineFillsThePage(t *testing.T) {
+	now := time.Now()
+
+	// timeline returns the entries of posts n down to 1, one minute apart
+	timeline := func(n int) []store.Cursor {
+		entries := make([]store.Cursor, n)
+		for i := range entries {
+			entries[i] = store.Cursor{CreatedAt: now.Add(-time.Duration(i) * time.Minute), ID: int64(n - i)}
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name     string
+		entries  []store.Cursor
+		hidden   []int64
+		after    int
+		want     []int64
+		wantNext int64
+		wantOK   bool
+	}{
+		{name: "nothing hidden", entries: timeline(10), want: []int64{10, 9, 8}, wantNext: 8, wantOK: true},
+		{name: "hidden posts are skipped", entries: timeline(10), hidden: []int64{9, 8}, want: []int64{10, 7, 6}, wantNext: 6, wantOK: true},
+		{name: "next page after hidden posts", entries: timeline(10), hidden: []int64{6, 5}, after: 8, want: []int64{7, 4, 3}, wantNext: 3, wantOK: true},
+		{name: "end of the timeline after hidden posts", entries: timeline(10), hidden: []int64{5, 4, 3}, after: 7, wantOK: false},
+		{name: "most posts hidden", entries: timeline(20), hidden: []int64{19, 18, 17, 16, 15, 14, 13, 12}, want: []int64{20, 11, 10}, wantNext: 10, wantOK: true},
+		{name: "everything hidden", entries: timeline(20), hidden: []int64{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8}, wantOK: false},
+		{name: "short timeline", entries: timeline(2), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := &memoryTimelinePostStore{hidden: make(map[int64]bool)}
+			for _, id := range tt.hidden {
+				posts.hidden[id] = true
+			}
+
+			app := &applicaion{
+				config: config{
+					timeline: timelineConfig{fanOutMaxFollowers: 10000, retention: time.Hour * 24},
+				},
+				store: store.Storage{
+					Posts:     posts,
+					Timelines: &memoryTimelineStore{entries: tt.entries},
+				},
+				logger: zap.NewNop().Sugar(),
+			}
+
+			fq := store.PaginatedFeedQuery{Limit: 3, Sort: "desc"}
+			if tt.after > 0 {
+				i := slices.IndexFunc(tt.entries, func(c store.Cursor) bool { return c.ID == int64(tt.after) })
+				fq.Cursor = &tt.entries[i]
+			}
+
+			feed, info, ok, err := app.readTimeline(context.Background(), &store.User{ID: 1}, fq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			if got := postIDs(feed); !slices.Equal(got, tt.want) {
+				t.Fatalf("page = %v, want %v", got, tt.want)
+			}
+			if info.Next == nil || info.Next.ID != tt.wantNext {
+				t.Fatalf("next = %v, want post %d", info.Next, tt.wantNext)
+			}
+			if (info.Prev != nil) != (fq.Cursor != nil) {
+				t.Fatalf("prev = %v with cursor %v", info.Prev, fq.Cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var errReservedUsername = errors.New("this username is reserved")
+
+// reservedUsernames can't be registered by anyone as they could be mistaken
+// for the staff or the service itself. Compared case insensitively.
+var reservedUsernames = map[string]bool{
+	"about":         true,
+	"abuse":         true,
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"auth":          true,
+	"billing":       true,
+	"help":          true,
+	"info":          true,
+	"me":            true,
+	"mod":           true,
+	"moderator":     true,
+	"official":      true,
+	"postmaster":    true,
+	"root":          true,
+	"security":      true,
+	"staff":         true,
+	"support":       true,
+	"system":        true,
+	"webmaster":     true,
+}
+
+func isReservedUsername(username string) bool {
+	return reservedUsernames[strings.ToLower(username)]
+}
+
+// getUserByUsernameHandler godoc
+//
+//	@Summary		Fetches a user profile by username
+//	@Description	Fetches a user profile by username, old usernames of renamed users redirect to them for a while
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	UserProfile
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *applicaion) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	user, err := app.store.Users.GetByUsername(r.Context(), username)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.Username != username {
+		// Note: lets clients update their links to the current username
+		w.Header().Set("Content-Location", "/v1/users/by-username/"+user.Username)
+	}
+
+	app.writeUserProfile(w, r, user)
+}
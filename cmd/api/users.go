@@ -11,17 +11,23 @@ import (
 
 type userKey string
 
+type UserProfile struct {
+	*store.User
+	// FollowsYou tells whether the user follows the viewer
+	FollowsYou bool `json:"follows_you"`
+}
+
 const userCtx userKey = "user"
 
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches a user profile by ID, the counts may lag behind by the cache duration. They include deleted and banned users, who are left out of the follower and following lists
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -47,11 +53,202 @@ func (app *applicaion) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	app.writeUserProfile(w, r, user)
+}
+
+// writeUserProfile answers with the profile of user as the viewer may see it,
+// a blocked one is not found.
+func (app *applicaion) writeUserProfile(w http.ResponseWriter, r *http.Request, user *store.User) {
+	viewer := getUserFromCtx(r)
+
+	blocked, err := app.store.Blocks.IsBlocked(r.Context(), viewer.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		// Note: the redirect of an old username would tell the user exists
+		w.Header().Del("Content-Location")
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	allowed, err := app.canSeePostsOf(r.Context(), viewer, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		user = privateProfile(user)
+	}
+
+	followsYou, err := app.store.Followers.IsFollowing(r.Context(), user.ID, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, UserProfile{User: user, FollowsYou: followsYou}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getCurrentUserHandler godoc
+//
+//	@Summary		Fetches the current user
+//	@Description	Fetches the profile of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.User
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *applicaion) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateProfilePayload struct {
+	// Version is the version of the profile the changes are based on
+	Version     *int    `json:"version" validate:"required"`
+	Username    *string `json:"username" validate:"omitempty,min=1,max=100"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,max=2048,len=0|http_url"`
+	Website     *string `json:"website" validate:"omitempty,max=2048,len=0|http_url"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	// IsPrivate makes new followers wait for approval, going public approves the pending ones
+	IsPrivate *bool `json:"is_private"`
+}
+
+// updateCurrentUserHandler godoc
+//
+//	@Summary		Updates the current user
+//	@Description	Updates the profile of the authenticated user, only the fields present are changed. Fails with 409 when the version is outdated
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *applicaion) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Username != nil && isReservedUsername(*payload.Username) {
+		app.badRequestResponse(w, r, errReservedUsername)
+		return
+	}
+
+	ctx := r.Context()
+
+	// the user in the context may come from the cache, edit the stored one
+	user, err := app.store.Users.GetByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if *payload.Version != user.Version {
+		app.conflictResponse(w, r, store.ErrEditConflict)
+		return
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.store.Users.UpdateProfile(ctx, user, app.config.usernameRedirectPeriod); err != nil {
+		switch err {
+		case store.ErrEditConflict, store.ErrDuplicateUsername, store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// deleteCurrentUserHandler godoc
+//
+//	@Summary		Deletes the current user
+//	@Description	Deletes the account of the authenticated user and logs them out everywhere. Logging in again within the restoration window restores it, afterwards it is purged with its posts and comments
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string	"User deleted"
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *applicaion) deleteCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.Users.SoftDelete(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.revokeAllTokens(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.invalidateUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // type FollowUser struct {
 // 	UserID int64 `json:"user_id"`
 // }
@@ -59,14 +256,17 @@ func (app *applicaion) getUserHandler(w http.ResponseWriter, r *http.Request) {
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID, following a private user sends them a follow request instead
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
+//	@Success		202		{string}	string	"Follow requested"
 //	@Success		204		{string}	string	"User followed"
 //	@Failure		400		{object}	error	"User payload missing"
 //	@Failure		404		{object}	error	"User not found"
+//	@Failure		403		{object}	error	"Blocked"
+//	@Failure		409		{object}	error	"Already followed or requested"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *applicaion) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -88,8 +288,39 @@ func (app *applicaion) followUserHandler(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
+	followedUser, err := app.getUser(ctx, followedID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if followedUser.IsPrivate {
+		if err := app.store.Followers.Request(ctx, followerUser.ID, followedID); err != nil {
+			switch err {
+			case store.ErrBlocked:
+				app.forbiddenResponse(w, r)
+			case store.ErrConflict:
+				app.conflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
 	if err := app.store.Followers.Follow(ctx, followerUser.ID, followedID); err != nil {
 		switch err {
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r)
+			return
 		case store.ErrConflict:
 			app.conflictResponse(w, r, err)
 			return
@@ -100,6 +331,8 @@ func (app *applicaion) followUserHandler(w http.ResponseWriter, r *http.Request)
 
 	}
 
+	app.backfillTimeline(ctx, followerUser.ID, followedUser)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -108,7 +341,7 @@ func (app *applicaion) followUserHandler(w http.ResponseWriter, r *http.Request)
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, it also cancels a pending follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -136,7 +369,10 @@ func (app *applicaion) unfollowUserHandler(w http.ResponseWriter, r *http.Reques
 
 	if err := app.store.Followers.Unfollow(ctx, followerUser.ID, unfollowedID); err != nil {
 		app.internalServerError(w, r, err)
+		return
 	}
+
+	app.purgeTimeline(ctx, followerUser.ID, unfollowedID)
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -0,0 +1,8 @@
+-- key is either "account:<email>" or "ip:<address>" so the same table tracks both
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key text PRIMARY KEY,
+    failures int NOT NULL DEFAULT 0,
+    lockouts int NOT NULL DEFAULT 0,
+    locked_until timestamp(0) with time zone,
+    last_failure_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+-- rows can be removed once the expiry has passed, the token is rejected anyway
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti uuid PRIMARY KEY,
+    user_id bigint NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- tokens issued before this moment are rejected (logout everywhere, password change, ban),
+-- it keeps microseconds like the issue time of the tokens
+ALTER TABLE
+    users
+ADD
+    COLUMN tokens_valid_after timestamp(6) with time zone NOT NULL DEFAULT '1970-01-01 00:00:00+00';
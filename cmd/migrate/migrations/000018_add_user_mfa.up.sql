@@ -0,0 +1,28 @@
+-- enabled_at stays NULL until the user confirmed the enrollment with a first code
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id bigint PRIMARY KEY,
+    secret text NOT NULL,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    enabled_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code bytea NOT NULL,
+    used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+-- refreshed access tokens keep the second factor of the login that started the family
+ALTER TABLE
+    refresh_tokens
+ADD
+    COLUMN mfa BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oidc_states;
+
+DROP TABLE IF EXISTS user_identities;
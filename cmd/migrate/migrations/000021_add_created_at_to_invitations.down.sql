@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_invitations_user_id;
+
+ALTER TABLE
+    user_invitations DROP COLUMN created_at;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE
+    users DROP COLUMN display_name,
+    DROP COLUMN bio,
+    DROP COLUMN avatar_url,
+    DROP COLUMN website,
+    DROP COLUMN location,
+    DROP COLUMN version;
\ No newline at end of file
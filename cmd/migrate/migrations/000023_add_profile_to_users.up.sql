@@ -0,0 +1,14 @@
+ALTER TABLE
+    users
+ADD
+    COLUMN display_name varchar(100) NOT NULL DEFAULT '',
+ADD
+    COLUMN bio varchar(500) NOT NULL DEFAULT '',
+ADD
+    COLUMN avatar_url varchar(2048) NOT NULL DEFAULT '',
+ADD
+    COLUMN website varchar(2048) NOT NULL DEFAULT '',
+ADD
+    COLUMN location varchar(100) NOT NULL DEFAULT '',
+ADD
+    COLUMN version INT NOT NULL DEFAULT 0;
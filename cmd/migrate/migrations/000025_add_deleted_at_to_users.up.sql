@@ -0,0 +1,8 @@
+ALTER TABLE
+    users
+ADD
+    COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS moderation_actions;
+
+ALTER TABLE
+    users DROP COLUMN suspended_until,
+    DROP COLUMN banned_at,
+    DROP COLUMN moderation_reason;
\ No newline at end of file
@@ -0,0 +1,25 @@
+ALTER TABLE
+    users
+ADD
+    COLUMN suspended_until timestamp(0) with time zone,
+ADD
+    COLUMN banned_at timestamp(0) with time zone,
+ADD
+    COLUMN moderation_reason text NOT NULL DEFAULT '';
+
+-- audit log of every moderation action, appeals included
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    moderator_id bigint,
+    action varchar(20) NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    appeal_note text NOT NULL DEFAULT '',
+    expires_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_user_id ON moderation_actions (user_id);
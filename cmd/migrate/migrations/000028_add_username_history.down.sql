@@ -0,0 +1 @@
+DROP TABLE IF EXISTS username_history;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS username_history (
+    id bigserial PRIMARY KEY,
+    -- user_id is NULL once the account is purged, the handle stays held
+    user_id bigint,
+    username varchar(255) NOT NULL,
+    redirect_until timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_username ON username_history (username, redirect_until);
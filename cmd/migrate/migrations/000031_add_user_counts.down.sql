@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+
+DROP TRIGGER IF EXISTS posts_update_count ON posts;
+
+DROP FUNCTION IF EXISTS update_posts_count;
+
+DROP TRIGGER IF EXISTS followers_update_counts ON followers;
+
+DROP FUNCTION IF EXISTS update_follow_counts;
+
+ALTER TABLE
+    users DROP COLUMN followers_count,
+    DROP COLUMN following_count,
+    DROP COLUMN posts_count;
\ No newline at end of file
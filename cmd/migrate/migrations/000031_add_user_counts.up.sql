@@ -0,0 +1,69 @@
+ALTER TABLE
+    users
+ADD
+    COLUMN followers_count INT NOT NULL DEFAULT 0,
+ADD
+    COLUMN following_count INT NOT NULL DEFAULT 0,
+ADD
+    COLUMN posts_count INT NOT NULL DEFAULT 0;
+
+UPDATE users u SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id);
+
+-- the counters follow every insert and delete, cascades included. They keep
+-- counting the follows and posts of deleted and banned users until those are
+-- purged, the lists leave them out.
+CREATE OR REPLACE FUNCTION update_follow_counts() RETURNS trigger AS $$
+DECLARE
+    f followers%ROWTYPE;
+    delta INT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        f := NEW;
+        delta := 1;
+    ELSE
+        f := OLD;
+        delta := -1;
+    END IF;
+
+    -- both rows in one statement locked in id order, follows in opposite
+    -- directions between the same users would deadlock otherwise
+    WITH locked AS (
+        SELECT id FROM users WHERE id IN (f.user_id, f.follower_id) ORDER BY id FOR UPDATE
+    )
+    UPDATE users u SET
+        followers_count = u.followers_count + CASE WHEN u.id = f.user_id THEN delta ELSE 0 END,
+        following_count = u.following_count + CASE WHEN u.id = f.follower_id THEN delta ELSE 0 END
+    FROM locked
+    WHERE u.id = locked.id;
+
+    RETURN f;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER followers_update_counts
+AFTER INSERT OR DELETE ON followers
+FOR EACH ROW EXECUTE FUNCTION update_follow_counts();
+
+CREATE OR REPLACE FUNCTION update_posts_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_update_count
+AFTER INSERT OR DELETE ON posts
+FOR EACH ROW EXECUTE FUNCTION update_posts_count();
+
+-- keyset pagination of the follower and following lists
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at DESC, follower_id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at DESC, user_id DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS timeline_entries;
\ No newline at end of file
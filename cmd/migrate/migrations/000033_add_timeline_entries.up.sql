@@ -0,0 +1,29 @@
+-- materialised home timelines, filled when a post is created
+CREATE TABLE IF NOT EXISTS timeline_entries (
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    author_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL,
+
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_id_created_at ON timeline_entries (user_id, created_at DESC, post_id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_post_id ON timeline_entries (post_id);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_entries_user_id_author_id ON timeline_entries (user_id, author_id);
+
+-- materialise the last 30 days, the default retention of the timelines
+INSERT INTO timeline_entries (user_id, post_id, author_id, created_at)
+SELECT f.follower_id, p.id, p.user_id, p.created_at
+FROM posts p
+JOIN followers f ON f.user_id = p.user_id
+WHERE p.created_at > NOW() - INTERVAL '30 days'
+UNION ALL
+SELECT p.user_id, p.id, p.user_id, p.created_at
+FROM posts p
+WHERE p.created_at > NOW() - INTERVAL '30 days'
+ON CONFLICT DO NOTHING;
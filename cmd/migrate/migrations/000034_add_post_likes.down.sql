@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_created_at;
+
+DROP INDEX IF EXISTS idx_comments_user_id;
+
+DROP TABLE IF EXISTS post_likes;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS post_likes (
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_likes_user_id ON post_likes (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+
+-- the engagement of the ranked feed window
+CREATE INDEX IF NOT EXISTS idx_post_likes_created_at ON post_likes (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments (created_at);
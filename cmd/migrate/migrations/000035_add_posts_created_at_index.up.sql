@@ -0,0 +1,2 @@
+-- keyset pagination of the public timeline and the trending tags window
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at DESC, id DESC);
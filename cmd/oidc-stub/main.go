@@ -0,0 +1,31 @@
+// Runs a stub OpenID provider to try the social login locally:
+//
+//	go run ./cmd/oidc-stub
+//	OIDC_PROVIDERS=stub OIDC_STUB_ISSUER=http://localhost:9000 OIDC_STUB_CLIENT_ID=gosocial \
+//	OIDC_STUB_CLIENT_SECRET=secret OIDC_STUB_REDIRECT_URL=http://localhost:5173/oauth/stub go run ./cmd/api
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/mayankpatidar275/go-social/internal/auth/oidc"
+	"github.com/mayankpatidar275/go-social/internal/env"
+)
+
+func main() {
+	addr := env.GetString("ADDR", ":9000")
+	issuer := env.GetString("OIDC_STUB_ISSUER", "http://localhost:9000")
+
+	stub, err := oidc.NewStubIssuer(
+		issuer,
+		env.GetString("OIDC_STUB_CLIENT_ID", "gosocial"),
+		env.GetString("OIDC_STUB_CLIENT_SECRET", "secret"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("stub issuer %s listening on %s", issuer, addr)
+	log.Fatal(http.ListenAndServe(addr, stub.Handler()))
+}
@@ -2,7 +2,10 @@ module github.com/mayankpatidar275/go-social
 
 go 1.23.1
 
-require github.com/go-playground/validator/v10 v10.23.0
+require (
+	github.com/go-playground/validator/v10 v10.23.0
+	go.uber.org/zap v1.27.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
@@ -10,7 +13,6 @@ require (
 	github.com/sendgrid/rest v2.6.9+incompatible // indirect
 	github.com/swaggo/files/v2 v2.0.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
 )
 
 require (
@@ -34,7 +36,7 @@ require (
 	github.com/sendgrid/sendgrid-go v3.16.0+incompatible
 	github.com/swaggo/http-swagger/v2 v2.0.2
 	github.com/swaggo/swag v1.16.4
-	golang.org/x/crypto v0.30.0 // indirect
+	golang.org/x/crypto v0.30.0
 	golang.org/x/net v0.32.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
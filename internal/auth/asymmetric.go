@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyringAuthenticator signs tokens with a private key from the keyring, so
+// other services can verify them with the public keys published in the JWKS.
+type KeyringAuthenticator struct {
+	keyring *Keyring
+	method  jwt.SigningMethod
+	aud     string
+	iss     string
+}
+
+func NewRS256Authenticator(keyring *Keyring, aud, iss string) (*KeyringAuthenticator, error) {
+	return newKeyringAuthenticator(keyring, jwt.SigningMethodRS256, aud, iss)
+}
+
+func NewEdDSAAuthenticator(keyring *Keyring, aud, iss string) (*KeyringAuthenticator, error) {
+	return newKeyringAuthenticator(keyring, jwt.SigningMethodEdDSA, aud, iss)
+}
+
+func newKeyringAuthenticator(keyring *Keyring, method jwt.SigningMethod, aud, iss string) (*KeyringAuthenticator, error) {
+	// every key must match the algorithm, otherwise verification would fail on rotation
+	for _, key := range keyring.Keys() {
+		if !keyMatchesMethod(key, method) {
+			return nil, fmt.Errorf("key %q can not be used with %s", key.ID, method.Alg())
+		}
+	}
+
+	return &KeyringAuthenticator{
+		keyring: keyring,
+		method:  method,
+		aud:     aud,
+		iss:     iss,
+	}, nil
+}
+
+func (a *KeyringAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	signing := a.keyring.Signing()
+
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = signing.ID
+
+	tokenString, err := token.SignedString(signing.Private)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *KeyringAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := a.keyring.Get(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		return key.Public, nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{a.method.Alg()}),
+	)
+}
+
+func (a *KeyringAuthenticator) JWKS() JWKSet {
+	return newJWKSet(a.method, a.keyring.Keys())
+}
+
+func keyMatchesMethod(key *Key, method jwt.SigningMethod) bool {
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		return method == jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		return method == jwt.SigningMethodEdDSA
+	default:
+		return false
+	}
+}
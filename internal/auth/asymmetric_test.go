@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims(aud, iss string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"aud": aud,
+		"iss": iss,
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"iat": time.Now().Unix(),
+	}
+}
+
+func signingKey(kid string, key crypto.Signer) *Key {
+	return &Key{ID: kid, Private: key, Public: key.Public()}
+}
+
+func TestKeyringAuthenticator(t *testing.T) {
+	tests := []struct {
+		name  string
+		newFn func(*Keyring, string, string) (*KeyringAuthenticator, error)
+		key   func(t *testing.T) crypto.Signer
+		alg   string
+	}{
+		{"RS256", NewRS256Authenticator, func(t *testing.T) crypto.Signer { return testRSAKey(t) }, "RS256"},
+		{"EdDSA", NewEdDSAAuthenticator, func(t *testing.T) crypto.Signer { return testEd25519Key(t) }, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := NewKeyring("current", signingKey("current", tt.key(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authenticator, err := tt.newFn(keyring, "gosocial", "gosocial")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := authenticator.GenerateToken(testClaims("gosocial", "gosocial"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := authenticator.ValidateToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != "current" || parsed.Header["alg"] != tt.alg {
+				t.Fatalf("header = %v, want kid current and alg %s", parsed.Header, tt.alg)
+			}
+
+			t.Run("tampered", func(t *testing.T) {
+				parts := strings.Split(token, ".")
+				claims := testClaims("gosocial", "gosocial")
+				claims["sub"] = 2
+				forged, err := authenticator.GenerateToken(claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the claims of one token with the signature of another
+				parts[1] = strings.Split(forged, ".")[1]
+				if _, err := authenticator.ValidateToken(strings.Join(parts, ".")); err == nil {
+					t.Fatal("accepted a token with tampered claims")
+				}
+			})
+
+			t.Run("wrong audience", func(t *testing.T) {
+				token, err := authenticator.GenerateToken(testClaims("other", "gosocial"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := authenticator.ValidateToken(token); err == nil {
+					t.Fatal("accepted a token for another audience")
+				}
+			})
+
+			t.Run("expired", func(t *testing.T) {
+				claims := testClaims("gosocial", "gosocial")
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				token, err := authenticator.GenerateToken(claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := authenticator.ValidateToken(token); err == nil {
+					t.Fatal("accepted an expired token")
+				}
+			})
+
+			t.Run("unknown kid", func(t *testing.T) {
+				other, err := NewKeyring("other", signingKey("other", tt.key(t)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				otherAuthenticator, err := tt.newFn(other, "gosocial", "gosocial")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				token, err := otherAuthenticator.GenerateToken(testClaims("gosocial", "gosocial"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := authenticator.ValidateToken(token); err == nil || !strings.Contains(err.Error(), `unknown key id "other"`) {
+					t.Fatalf("err = %v, want an unknown key id", err)
+				}
+			})
+		})
+	}
+}
+
+func TestKeyringAuthenticatorRotation(t *testing.T) {
+	old, current := testEd25519Key(t), testEd25519Key(t)
+
+	before, err := NewKeyring("old", signingKey("old", old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	beforeAuthenticator, err := NewEdDSAAuthenticator(before, "gosocial", "gosocial")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := beforeAuthenticator.GenerateToken(testClaims("gosocial", "gosocial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key is retired, only its public part is left
+	after, err := NewKeyring("current", signingKey("current", current), &Key{ID: "old", Public: old.Public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterAuthenticator, err := NewEdDSAAuthenticator(after, "gosocial", "gosocial")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := afterAuthenticator.ValidateToken(token); err != nil {
+		t.Fatalf("a token signed before the rotation was rejected: %v", err)
+	}
+
+	newToken, err := afterAuthenticator.GenerateToken(testClaims("gosocial", "gosocial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := afterAuthenticator.ValidateToken(newToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "current" {
+		t.Fatalf("signed with kid %v, want current", parsed.Header["kid"])
+	}
+}
+
+func TestKeyringAuthenticatorRejectsOtherAlgorithms(t *testing.T) {
+	rsaKeyring, err := NewKeyring("rsa", signingKey("rsa", testRSAKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEdDSAAuthenticator(rsaKeyring, "gosocial", "gosocial"); err == nil {
+		t.Fatal("an RSA key was accepted for EdDSA")
+	}
+
+	edKeyring, err := NewKeyring("ed", signingKey("ed", testEd25519Key(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRS256Authenticator(edKeyring, "gosocial", "gosocial"); err == nil {
+		t.Fatal("an Ed25519 key was accepted for RS256")
+	}
+
+	// a token signed with the shared secret must not pass for the keyring
+	hmacToken, err := NewJWTAuthenticator("secret", "gosocial", "gosocial").GenerateToken(testClaims("gosocial", "gosocial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edAuthenticator, err := NewEdDSAAuthenticator(edKeyring, "gosocial", "gosocial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := edAuthenticator.ValidateToken(hmacToken); err == nil {
+		t.Fatal("accepted an HS256 token")
+	}
+}
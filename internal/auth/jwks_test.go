@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestJWKS(t *testing.T) {
+	t.Run("RS256", func(t *testing.T) {
+		key := testRSAKey(t)
+		keyring, err := NewKeyring("b", signingKey("b", key), &Key{ID: "a", Public: key.Public()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator, err := NewRS256Authenticator(keyring, "gosocial", "gosocial")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := authenticator.JWKS()
+		if len(set.Keys) != 2 || set.Keys[0].Kid != "a" || set.Keys[1].Kid != "b" {
+			t.Fatalf("keys = %+v, want a then b", set.Keys)
+		}
+
+		for _, jwk := range set.Keys {
+			if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.Use != "sig" {
+				t.Fatalf("jwk = %+v, want an RS256 signature key", jwk)
+			}
+
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if new(big.Int).SetBytes(n).Cmp(key.N) != 0 {
+				t.Fatal("the modulus doesn't match the key")
+			}
+
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(new(big.Int).SetBytes(e).Int64()) != key.E {
+				t.Fatal("the exponent doesn't match the key")
+			}
+		}
+	})
+
+	t.Run("EdDSA", func(t *testing.T) {
+		key := testEd25519Key(t)
+		keyring, err := NewKeyring("ed", signingKey("ed", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator, err := NewEdDSAAuthenticator(keyring, "gosocial", "gosocial")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := authenticator.JWKS()
+		if len(set.Keys) != 1 {
+			t.Fatalf("got %d keys, want 1", len(set.Keys))
+		}
+
+		jwk := set.Keys[0]
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.Kid != "ed" {
+			t.Fatalf("jwk = %+v, want an Ed25519 key", jwk)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(x, key.Public().(ed25519.PublicKey)) {
+			t.Fatal("x doesn't match the key")
+		}
+
+		// the private key never leaves the keyring
+		data, err := json.Marshal(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(`"d"`)) || jwk.N != "" || jwk.E != "" {
+			t.Fatalf("unexpected members in %s", data)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Key is a signing key identified by its kid. Retired keys only have the public
+// part and are kept around to verify tokens that were signed before the rotation.
+type Key struct {
+	ID      string
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// Keyring holds the active signing key and every key that is still accepted for verification.
+//
+// Rotating a key:
+//  1. add the new key file, it is published in the JWKS but not used yet
+//  2. once every verifier picked it up, switch the signing kid to the new key
+//  3. after the longest token lifetime has passed, remove the old key
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeyring(signingKID string, keys ...*Key) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]*Key, len(keys))}
+
+	for _, key := range keys {
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	signing, ok := kr.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingKID)
+	}
+	if signing.Private == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKID)
+	}
+
+	kr.signing = signing
+
+	return kr, nil
+}
+
+// LoadKeyring reads every <kid>.pem file in dir. Files can hold a PKCS#8 (or
+// PKCS#1 for RSA) private key or, for retired keys, a PKIX public key.
+//
+// A key can be generated with:
+//
+//	openssl genpkey -algorithm ed25519 -out <kid>.pem
+//	openssl genpkey -algorithm rsa -pkeyopt rsa_keygen_bits:2048 -out <kid>.pem
+func LoadKeyring(dir, signingKID string) (*Keyring, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no keys found in %q", dir)
+	}
+
+	keys := make([]*Key, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(signingKID, keys...)
+}
+
+func parseKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	key := &Key{ID: kid}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+		key.Private = signer
+		key.Public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = parsed
+		key.Public = parsed.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.Public = parsed
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch key.Public.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.Public)
+	}
+
+	return key, nil
+}
+
+func (kr *Keyring) Signing() *Key {
+	return kr.signing
+}
+
+func (kr *Keyring) Get(kid string) (*Key, bool) {
+	key, ok := kr.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key sorted by kid, the signing key included.
+func (kr *Keyring) Keys() []*Key {
+	keys := make([]*Key, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
@@ -0,0 +1,195 @@
+package oidc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// StubIssuer is a minimal OpenID provider for local development and tests. The
+// authorize endpoint approves every request right away, the user is picked with
+// the sub, email and email_verified query parameters.
+type StubIssuer struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          ed25519.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]stubGrant
+}
+
+type stubGrant struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	sub           string
+	email         string
+	emailVerified bool
+	expiry        time.Time
+}
+
+const stubKeyID = "stub"
+
+func NewStubIssuer(issuer, clientID, clientSecret string) (*StubIssuer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StubIssuer{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]stubGrant),
+	}, nil
+}
+
+func (s *StubIssuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	return mux
+}
+
+func (s *StubIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeStubJSON(w, http.StatusOK, metadata{
+		Issuer:                s.issuer,
+		AuthorizationEndpoint: s.issuer + "/authorize",
+		TokenEndpoint:         s.issuer + "/token",
+		JWKSURI:               s.issuer + "/jwks",
+	})
+}
+
+func (s *StubIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.Public().(ed25519.PublicKey)
+
+	writeStubJSON(w, http.StatusOK, jwkSet{Keys: []jwk{{
+		Kty: "OKP",
+		Kid: stubKeyID,
+		Use: "sig",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+	}}})
+}
+
+func (s *StubIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	if qs.Get("client_id") != s.clientID || qs.Get("code_challenge_method") != "S256" || qs.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(qs.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code, err := RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grant := stubGrant{
+		redirectURI:   redirectURI.String(),
+		nonce:         qs.Get("nonce"),
+		codeChallenge: qs.Get("code_challenge"),
+		sub:           qs.Get("sub"),
+		email:         qs.Get("email"),
+		emailVerified: qs.Get("email_verified") != "false",
+		expiry:        time.Now().Add(time.Minute),
+	}
+	if grant.sub == "" {
+		grant.sub = "stub-user"
+	}
+	if grant.email == "" {
+		grant.email = grant.sub + "@example.com"
+	}
+
+	s.mu.Lock()
+	s.codes[code] = grant
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", qs.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *StubIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	s.mu.Lock()
+	grant, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	switch {
+	case !ok || grant.expiry.Before(time.Now()):
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case r.PostForm.Get("client_id") != s.clientID || r.PostForm.Get("client_secret") != s.clientSecret:
+		writeStubJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case r.PostForm.Get("redirect_uri") != grant.redirectURI:
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case CodeChallengeS256(r.PostForm.Get("code_verifier")) != grant.codeChallenge:
+		writeStubJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            s.issuer,
+		"aud":            s.clientID,
+		"sub":            grant.sub,
+		"email":          grant.email,
+		"email_verified": grant.emailVerified,
+		"nonce":          grant.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute * 5).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = stubKeyID
+
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeStubJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeStubJSON(w, http.StatusOK, map[string]any{
+		"access_token": code,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeStubJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
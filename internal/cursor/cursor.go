@@ -0,0 +1,77 @@
+// Package cursor encodes list positions into opaque, signed pagination
+// cursors so clients can't forge or tamper with them.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+const (
+	payloadSize = 17
+	macSize     = 16
+)
+
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// Encode returns the cursor for the position in the list named by scope.
+// Backward cursors ask for the rows before the position.
+func (s *Signer) Encode(scope string, position store.Cursor, backward bool) string {
+	payload := make([]byte, payloadSize)
+	binary.BigEndian.PutUint64(payload[0:8], uint64(position.CreatedAt.UnixNano()))
+	binary.BigEndian.PutUint64(payload[8:16], uint64(position.ID))
+	if backward {
+		payload[16] = 1
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.mac(scope, payload))
+}
+
+// Decode checks the cursor was issued for the list named by scope and
+// returns its position.
+func (s *Signer) Decode(scope, cursor string) (store.Cursor, bool, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return store.Cursor{}, false, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != payloadSize {
+		return store.Cursor{}, false, ErrInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, s.mac(scope, payload)) {
+		return store.Cursor{}, false, ErrInvalid
+	}
+
+	position := store.Cursor{
+		CreatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(payload[0:8]))),
+		ID:        int64(binary.BigEndian.Uint64(payload[8:16])),
+	}
+
+	return position, payload[16] == 1, nil
+}
+
+func (s *Signer) mac(scope string, payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(scope))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return h.Sum(nil)[:macSize]
+}
@@ -0,0 +1,85 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+var position = store.Cursor{
+	CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+	ID:        42,
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := NewSigner("secret")
+
+	for _, backward := range []bool{false, true} {
+		cursor := s.Encode("feed:1", position, backward)
+
+		got, gotBackward, err := s.Decode("feed:1", cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.CreatedAt.Equal(position.CreatedAt) || got.ID != position.ID {
+			t.Errorf("position = %+v, want %+v", got, position)
+		}
+		if gotBackward != backward {
+			t.Errorf("backward = %v, want %v", gotBackward, backward)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	s := NewSigner("secret")
+	cursor := s.Encode("feed:1", position, false)
+	payload, mac, _ := strings.Cut(cursor, ".")
+
+	flip := func(encoded string, i int) string {
+		b, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b[i] ^= 1
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name   string
+		scope  string
+		cursor string
+		signer *Signer
+	}{
+		{name: "other scope", scope: "feed:2", cursor: cursor},
+		{name: "other list", scope: "posts", cursor: cursor},
+		{name: "other secret", scope: "feed:1", cursor: cursor, signer: NewSigner("another secret")},
+		{name: "tampered time", scope: "feed:1", cursor: flip(payload, 7) + "." + mac},
+		{name: "tampered id", scope: "feed:1", cursor: flip(payload, 15) + "." + mac},
+		{name: "tampered direction", scope: "feed:1", cursor: flip(payload, 16) + "." + mac},
+		{name: "tampered mac", scope: "feed:1", cursor: payload + "." + flip(mac, 0)},
+		{name: "truncated mac", scope: "feed:1", cursor: cursor[:len(cursor)-2]},
+		{name: "missing mac", scope: "feed:1", cursor: payload},
+		{name: "empty mac", scope: "feed:1", cursor: payload + "."},
+		{name: "empty", scope: "feed:1", cursor: ""},
+		{name: "not base64", scope: "feed:1", cursor: "!!!." + mac},
+		{name: "short payload", scope: "feed:1", cursor: payload[:10] + "." + mac},
+		{name: "padded", scope: "feed:1", cursor: payload + "=." + mac},
+		{name: "garbage", scope: "feed:1", cursor: "not a cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := s
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+
+			if _, _, err := signer.Decode(tt.scope, tt.cursor); err != ErrInvalid {
+				t.Fatalf("got %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package export writes the personal data of a user to a ZIP archive.
+package export
+
+import (
+	"archive/zip"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(templatesFS, "templates/index.html.tmpl"))
+
+type profile struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+	Role        string `json:"role"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type post struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	Version   int      `json:"version"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+type comment struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+type archive struct {
+	GeneratedAt time.Time
+	Profile     profile
+	Posts       []post
+	Comments    []comment
+	Followers   []store.Connection
+	Following   []store.Connection
+	Sessions    []store.Session
+}
+
+// Write writes the archive: one JSON file per kind of data and an index.html
+// to browse them.
+func Write(w io.Writer, data *store.PersonalData, generatedAt time.Time) error {
+	a := newArchive(data, generatedAt)
+
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", a.Profile},
+		{"posts.json", a.Posts},
+		{"comments.json", a.Comments},
+		{"followers.json", a.Followers},
+		{"following.json", a.Following},
+		{"sessions.json", a.Sessions},
+	}
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("index.html")
+	if err != nil {
+		return err
+	}
+
+	if err := indexTemplate.Execute(f, a); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func newArchive(data *store.PersonalData, generatedAt time.Time) archive {
+	a := archive{
+		GeneratedAt: generatedAt,
+		Profile: profile{
+			ID:          data.User.ID,
+			Username:    data.User.Username,
+			Email:       data.User.Email,
+			DisplayName: data.User.DisplayName,
+			Bio:         data.User.Bio,
+			AvatarURL:   data.User.AvatarURL,
+			Website:     data.User.Website,
+			Location:    data.User.Location,
+			Role:        data.User.Role.Name,
+			CreatedAt:   data.User.CreatedAt,
+		},
+		Posts:     make([]post, 0, len(data.Posts)),
+		Comments:  make([]comment, 0, len(data.Comments)),
+		Followers: data.Followers,
+		Following: data.Following,
+		Sessions:  data.Sessions,
+	}
+
+	for _, p := range data.Posts {
+		a.Posts = append(a.Posts, post{
+			ID:        p.ID,
+			Title:     p.Title,
+			Content:   p.Content,
+			Tags:      p.Tags,
+			Version:   p.Version,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+
+	for _, c := range data.Comments {
+		a.Comments = append(a.Comments, comment{
+			ID:        c.ID,
+			PostID:    c.PostID,
+			Content:   c.Content,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+
+	return a
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+func TestWrite(t *testing.T) {
+	data := &store.PersonalData{
+		User: &store.User{
+			ID:       1,
+			Username: "alice",
+			Email:    "alice@example.com",
+			Bio:      "<script>alert(1)</script>",
+			Role:     store.Role{Name: "user"},
+		},
+		Posts: []store.Post{
+			{ID: 10, UserID: 1, Title: "hello", Content: "first post", Tags: []string{"go"}},
+		},
+		Comments: []store.Comment{
+			{ID: 20, PostID: 10, UserID: 1, Content: "a comment"},
+		},
+		Followers: []store.Connection{{UserID: 2, Username: "bob"}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, data, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = content
+	}
+
+	for _, name := range []string{"profile.json", "posts.json", "comments.json", "followers.json", "following.json", "sessions.json", "index.html"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s is missing from the archive", name)
+		}
+	}
+
+	var p profile
+	if err := json.Unmarshal(files["profile.json"], &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Username != "alice" || p.Email != "alice@example.com" || p.Role != "user" {
+		t.Errorf("profile = %+v", p)
+	}
+
+	var posts []post
+	if err := json.Unmarshal(files["posts.json"], &posts); err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Title != "hello" || len(posts[0].Tags) != 1 {
+		t.Errorf("posts = %+v", posts)
+	}
+
+	var followers []store.Connection
+	if err := json.Unmarshal(files["followers.json"], &followers); err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0].Username != "bob" {
+		t.Errorf("followers = %+v", followers)
+	}
+
+	index := string(files["index.html"])
+	if strings.Contains(index, "<script>alert(1)</script>") {
+		t.Error("index.html doesn't escape the user data")
+	}
+	if !strings.Contains(index, "alice") {
+		t.Error("index.html doesn't show the username")
+	}
+}
@@ -0,0 +1,230 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// memoryStore follows the failure counting of store.LoginAttemptStore.
+type memoryStore struct {
+	attempts map[string]*store.LoginAttempt
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{attempts: make(map[string]*store.LoginAttempt)}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (*store.LoginAttempt, error) {
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return attempt, nil
+}
+
+func (s *memoryStore) RecordFailure(_ context.Context, key string, window time.Duration) (*store.LoginAttempt, error) {
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = &store.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+	if time.Since(attempt.LastFailure) > window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailure = time.Now()
+	return attempt, nil
+}
+
+func (s *memoryStore) Lock(_ context.Context, key string, until time.Time) error {
+	attempt := s.attempts[key]
+	attempt.LockedUntil = until
+	attempt.Lockouts++
+	attempt.Failures = 0
+	return nil
+}
+
+func (s *memoryStore) Reset(_ context.Context, key string) error {
+	delete(s.attempts, key)
+	return nil
+}
+
+var testConfig = Config{
+	MaxAccountAttempts: 3,
+	MaxIPAttempts:      10,
+	Window:             time.Minute * 15,
+	BaseDuration:       time.Minute,
+	MaxDuration:        time.Hour,
+	Enabled:            true,
+}
+
+func TestLockDuration(t *testing.T) {
+	tracker := NewTracker(newMemoryStore(), testConfig)
+
+	tests := []struct {
+		lockouts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute * 2},
+		{2, time.Minute * 4},
+		{5, time.Minute * 32},
+		{6, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := tracker.lockDuration(tt.lockouts); got != tt.want {
+			t.Errorf("lockDuration(%d) = %v, want %v", tt.lockouts, got, tt.want)
+		}
+	}
+}
+
+func TestFailLocksAtTheLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		// lockouts the key had before
+		lockouts int
+		want     time.Duration
+	}{
+		{"first lockout", 0, time.Minute},
+		{"second lockout doubles", 1, time.Minute * 2},
+		{"capped lockout", 10, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newMemoryStore()
+			s.attempts["account:a"] = &store.LoginAttempt{Key: "account:a", Lockouts: tt.lockouts, LastFailure: time.Now()}
+			tracker := NewTracker(s, testConfig)
+
+			for i := 1; i < testConfig.MaxAccountAttempts; i++ {
+				duration, err := tracker.Fail(ctx, "account:a", testConfig.MaxAccountAttempts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if duration != 0 {
+					t.Fatalf("failure %d locked the key for %v", i, duration)
+				}
+			}
+
+			duration, err := tracker.Fail(ctx, "account:a", testConfig.MaxAccountAttempts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if duration != tt.want {
+				t.Fatalf("lockout = %v, want %v", duration, tt.want)
+			}
+
+			retryAfter, err := tracker.Check(ctx, "account:a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retryAfter <= 0 || retryAfter > tt.want {
+				t.Fatalf("Check = %v, want up to %v", retryAfter, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		attempt *store.LoginAttempt
+		locked  bool
+	}{
+		{"unknown key", nil, false},
+		{"failures without a lock", &store.LoginAttempt{Failures: 2}, false},
+		{"expired lock", &store.LoginAttempt{LockedUntil: time.Now().Add(-time.Minute)}, false},
+		{"current lock", &store.LoginAttempt{LockedUntil: time.Now().Add(time.Minute)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemoryStore()
+			if tt.attempt != nil {
+				s.attempts["ip:127.0.0.1"] = tt.attempt
+			}
+
+			retryAfter, err := NewTracker(s, testConfig).Check(ctx, "ip:127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locked := retryAfter > 0; locked != tt.locked {
+				t.Fatalf("Check = %v, want locked %v", retryAfter, tt.locked)
+			}
+		})
+	}
+}
+
+func TestResetForgetsTheFailures(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore()
+	tracker := NewTracker(s, testConfig)
+
+	for i := 1; i < testConfig.MaxAccountAttempts; i++ {
+		if _, err := tracker.Fail(ctx, "account:a", testConfig.MaxAccountAttempts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tracker.Reset(ctx, "account:a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failures before the reset don't count towards the limit
+	for i := 1; i < testConfig.MaxAccountAttempts; i++ {
+		duration, err := tracker.Fail(ctx, "account:a", testConfig.MaxAccountAttempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if duration != 0 {
+			t.Fatalf("failure %d after the reset locked the key", i)
+		}
+	}
+}
+
+func TestDisabledTrackerNeverLocks(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig
+	cfg.Enabled = false
+	s := newMemoryStore()
+	tracker := NewTracker(s, cfg)
+
+	for i := 0; i < cfg.MaxAccountAttempts*2; i++ {
+		duration, err := tracker.Fail(ctx, "account:a", cfg.MaxAccountAttempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if duration != 0 {
+			t.Fatal("a disabled tracker locked the key")
+		}
+	}
+
+	if len(s.attempts) != 0 {
+		t.Fatal("a disabled tracker recorded failures")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{AccountKey("Alice@Example.com"), "account:alice@example.com"},
+		{IPKey("203.0.113.7:52100"), "ip:203.0.113.7"},
+		{IPKey("203.0.113.7"), "ip:203.0.113.7"},
+		{IPKey("[2001:db8::1]:443"), "ip:2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("key = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
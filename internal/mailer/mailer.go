@@ -3,9 +3,15 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "GoSocial"
-	maxRetires          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName              = "GoSocial"
+	maxRetires            = 3
+	UserWelcomeTemplate   = "user_invitations.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+
+	EmailChangeTemplate       = "email_change.tmpl"
+	EmailChangeNoticeTemplate = "email_change_notice.tmpl"
+
+	DataExportTemplate = "data_export.tmpl"
 )
 
 //go:embed "templates"
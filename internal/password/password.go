@@ -0,0 +1,155 @@
+// Package password checks new passwords against a strength policy.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+const (
+	RuleLength           = "length"
+	RuleCharacterClasses = "character_classes"
+	RuleCommon           = "common"
+	RulePersonal         = "personal"
+)
+
+// minPersonalLength ignores usernames and email parts too short to matter
+const minPersonalLength = 3
+
+type Policy struct {
+	MinLength int
+	// MaxLength is in bytes, bcrypt ignores everything after 72 bytes
+	MaxLength int
+	// MinCharacterClasses out of lowercase, uppercase, digits and symbols
+	MinCharacterClasses int
+}
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule the password breaks.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+
+	return "password does not meet the policy: " + strings.Join(messages, ", ")
+}
+
+// Validate returns a *PolicyError when the password breaks the policy. The
+// personal values, such as the username and email, must not appear in it.
+func (p Policy) Validate(password string, personal ...string) error {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length < p.MinLength:
+		violations = append(violations, Violation{
+			Rule:    RuleLength,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	case len(password) > p.MaxLength:
+		violations = append(violations, Violation{
+			Rule:    RuleLength,
+			Message: fmt.Sprintf("must be at most %d bytes long", p.MaxLength),
+		})
+	}
+
+	if classes := characterClasses(password); classes < p.MinCharacterClasses {
+		violations = append(violations, Violation{
+			Rule:    RuleCharacterClasses,
+			Message: fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharacterClasses),
+		})
+	}
+
+	lower := strings.ToLower(password)
+
+	if _, ok := commonPasswords[lower]; ok {
+		violations = append(violations, Violation{
+			Rule:    RuleCommon,
+			Message: "is too common",
+		})
+	}
+
+	if containsPersonal(lower, personal) {
+		violations = append(violations, Violation{
+			Rule:    RulePersonal,
+			Message: "must not contain the username or email",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+
+	return classes
+}
+
+func containsPersonal(lowerPassword string, personal []string) bool {
+	for _, value := range personal {
+		// for emails the local part is what people reuse
+		value, _, _ = strings.Cut(strings.ToLower(value), "@")
+
+		if len(value) >= minPersonalLength && strings.Contains(lowerPassword, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadCommonPasswords(file string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
@@ -0,0 +1,90 @@
+package password
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var policy = Policy{MinLength: 8, MaxLength: 72, MinCharacterClasses: 3}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		personal  []string
+		wantRules []string
+	}{
+		{name: "strong", password: "Tr0ub4dor&3x", personal: []string{"alice", "alice@example.com"}},
+		{name: "too short", password: "Ab1!", wantRules: []string{RuleLength}},
+		{name: "min length", password: "Zq7!kd9W"},
+		{name: "length counts characters", password: "Éüñ7çàöx"},
+		{name: "too long", password: "Aa1!" + strings.Repeat("x", 69), wantRules: []string{RuleLength}},
+		{name: "max length", password: "Aa1!" + strings.Repeat("x", 68)},
+		{name: "one class", password: "zqxwvutsrk", wantRules: []string{RuleCharacterClasses}},
+		{name: "two classes", password: "zqxwvut123", wantRules: []string{RuleCharacterClasses}},
+		{name: "common", password: "P@ssw0rd", wantRules: []string{RuleCommon}},
+		{name: "common in another case", password: "QWERTY123", wantRules: []string{RuleCharacterClasses, RuleCommon}},
+		{name: "contains the username", password: "xAlice42!", personal: []string{"alice"}, wantRules: []string{RulePersonal}},
+		{
+			name:      "contains the email local part",
+			password:  "Bob.Smith#1",
+			personal:  []string{"bsmith", "bob.smith@example.com"},
+			wantRules: []string{RulePersonal},
+		},
+		{name: "contains the email domain", password: "Example.com1", personal: []string{"bob@example.com"}},
+		{name: "short personal values are ignored", password: "Zq7!al9W", personal: []string{"al"}},
+		{
+			name:      "every rule",
+			password:  "alice",
+			personal:  []string{"alice"},
+			wantRules: []string{RuleLength, RuleCharacterClasses, RulePersonal},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.personal...)
+
+			var gotRules []string
+			if err != nil {
+				var policyErr *PolicyError
+				if !errors.As(err, &policyErr) {
+					t.Fatalf("got %T, want *PolicyError", err)
+				}
+				for _, v := range policyErr.Violations {
+					gotRules = append(gotRules, v.Rule)
+				}
+			}
+
+			if !slices.Equal(gotRules, tt.wantRules) {
+				t.Fatalf("rules = %v, want %v", gotRules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestPolicyErrorListsEveryViolation(t *testing.T) {
+	err := policy.Validate("alice", "alice")
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	for _, message := range []string{"at least 8 characters", "must mix at least 3", "must not contain the username"} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("%q doesn't mention %q", err.Error(), message)
+		}
+	}
+}
+
+func TestCommonPasswordsAreLoaded(t *testing.T) {
+	if len(commonPasswords) == 0 {
+		t.Fatal("no common passwords")
+	}
+	for password := range commonPasswords {
+		if strings.HasPrefix(password, "#") || password != strings.ToLower(password) {
+			t.Errorf("unexpected entry %q", password)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package ranking scores the candidate posts of the ranked feed. Each signal
+// scores a candidate on its own and the score is their weighted sum, so it
+// can be explained signal by signal. Scores only depend on the candidates
+// and the viewer, the same inputs always rank the same way.
+package ranking
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Candidate is a post to rank.
+type Candidate struct {
+	PostID    int64
+	CreatedAt time.Time
+	Tags      []string
+	Comments  int
+	Likes     int
+	// Interactions counts the comments and likes of the viewer on the other
+	// posts of the author
+	Interactions int
+}
+
+// Viewer is who the feed is ranked for.
+type Viewer struct {
+	// Now is the time the ranking is computed as of
+	Now time.Time
+	// Tags counts the tags of the posts the viewer engaged with
+	Tags map[string]int
+}
+
+// Signal scores one aspect of a candidate.
+type Signal interface {
+	Name() string
+	Value(Candidate, Viewer) float64
+}
+
+type WeightedSignal struct {
+	Signal Signal
+	Weight float64
+}
+
+// Contribution is the part of the score of a candidate due to one signal.
+type Contribution struct {
+	Signal string  `json:"signal"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+}
+
+type Explanation struct {
+	Score   float64        `json:"score"`
+	Signals []Contribution `json:"signals"`
+}
+
+// Ranked is a candidate with its score, Index is its index in the
+// candidates given to Rank.
+type Ranked struct {
+	Index int
+	Explanation
+}
+
+type Ranker struct {
+	signals []WeightedSignal
+}
+
+func New(signals ...WeightedSignal) *Ranker {
+	return &Ranker{signals: signals}
+}
+
+// Default ranks fresh posts first, then the discussed and liked ones, those
+// of the authors the viewer interacts with and on the tags they engage with.
+func Default() *Ranker {
+	return New(
+		WeightedSignal{Signal: Recency{HalfLife: time.Hour * 12}, Weight: 4},
+		WeightedSignal{Signal: Comments{}, Weight: 1},
+		WeightedSignal{Signal: Likes{}, Weight: 1},
+		WeightedSignal{Signal: AuthorAffinity{}, Weight: 2},
+		WeightedSignal{Signal: TagOverlap{}, Weight: 2},
+	)
+}
+
+// Rank scores the candidates, best first. Ties go to the newest post.
+func (r *Ranker) Rank(candidates []Candidate, viewer Viewer) []Ranked {
+	ranked := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = Ranked{Index: i, Explanation: r.explain(c, viewer)}
+	}
+
+	slices.SortStableFunc(ranked, func(a, b Ranked) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+
+		ca, cb := candidates[a.Index], candidates[b.Index]
+		if !ca.CreatedAt.Equal(cb.CreatedAt) {
+			return cb.CreatedAt.Compare(ca.CreatedAt)
+		}
+		switch {
+		case ca.PostID > cb.PostID:
+			return -1
+		case ca.PostID < cb.PostID:
+			return 1
+		}
+		return 0
+	})
+
+	return ranked
+}
+
+func (r *Ranker) explain(c Candidate, viewer Viewer) Explanation {
+	e := Explanation{Signals: make([]Contribution, len(r.signals))}
+	for i, s := range r.signals {
+		value := s.Signal.Value(c, viewer)
+		e.Signals[i] = Contribution{
+			Signal: s.Signal.Name(),
+			Value:  value,
+			Weight: s.Weight,
+			Score:  value * s.Weight,
+		}
+		e.Score += value * s.Weight
+	}
+
+	return e
+}
+
+// Recency halves every HalfLife, from 1 for a post created at Now.
+type Recency struct {
+	HalfLife time.Duration
+}
+
+func (Recency) Name() string { return "recency" }
+
+func (s Recency) Value(c Candidate, v Viewer) float64 {
+	age := max(v.Now.Sub(c.CreatedAt), 0)
+	return math.Exp2(-float64(age) / float64(s.HalfLife))
+}
+
+// Comments grows with the log of the comment count, the first comments
+// matter most.
+type Comments struct{}
+
+func (Comments) Name() string { return "comments" }
+
+func (Comments) Value(c Candidate, _ Viewer) float64 {
+	return math.Log1p(float64(c.Comments))
+}
+
+// Likes grows with the log of the like count.
+type Likes struct{}
+
+func (Likes) Name() string { return "likes" }
+
+func (Likes) Value(c Candidate, _ Viewer) float64 {
+	return math.Log1p(float64(c.Likes))
+}
+
+// AuthorAffinity grows with the log of the interactions of the viewer with
+// the author.
+type AuthorAffinity struct{}
+
+func (AuthorAffinity) Name() string { return "author_affinity" }
+
+func (AuthorAffinity) Value(c Candidate, _ Viewer) float64 {
+	return math.Log1p(float64(c.Interactions))
+}
+
+// TagOverlap is the share of the tags of the post the viewer engaged with.
+type TagOverlap struct{}
+
+func (TagOverlap) Name() string { return "tag_overlap" }
+
+func (TagOverlap) Value(c Candidate, v Viewer) float64 {
+	if len(c.Tags) == 0 {
+		return 0
+	}
+
+	var matched int
+	for _, tag := range c.Tags {
+		if v.Tags[tag] > 0 {
+			matched++
+		}
+	}
+
+	return float64(matched) / float64(len(c.Tags))
+}
@@ -0,0 +1,146 @@
+package ranking
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSignals(t *testing.T) {
+	viewer := Viewer{Now: now, Tags: map[string]int{"go": 3, "sql": 1}}
+
+	tests := []struct {
+		name      string
+		signal    Signal
+		candidate Candidate
+		want      float64
+	}{
+		{name: "new post", signal: Recency{HalfLife: time.Hour}, candidate: Candidate{CreatedAt: now}, want: 1},
+		{name: "one half life", signal: Recency{HalfLife: time.Hour}, candidate: Candidate{CreatedAt: now.Add(-time.Hour)}, want: 0.5},
+		{name: "two half lives", signal: Recency{HalfLife: time.Hour}, candidate: Candidate{CreatedAt: now.Add(-2 * time.Hour)}, want: 0.25},
+		{name: "future post", signal: Recency{HalfLife: time.Hour}, candidate: Candidate{CreatedAt: now.Add(time.Hour)}, want: 1},
+		{name: "no comments", signal: Comments{}, candidate: Candidate{}, want: 0},
+		{name: "comments", signal: Comments{}, candidate: Candidate{Comments: 9}, want: math.Log(10)},
+		{name: "likes", signal: Likes{}, candidate: Candidate{Likes: 99}, want: math.Log(100)},
+		{name: "no interactions", signal: AuthorAffinity{}, candidate: Candidate{}, want: 0},
+		{name: "interactions", signal: AuthorAffinity{}, candidate: Candidate{Interactions: 1}, want: math.Log(2)},
+		{name: "no tags", signal: TagOverlap{}, candidate: Candidate{}, want: 0},
+		{name: "some tags", signal: TagOverlap{}, candidate: Candidate{Tags: []string{"go", "rust", "sql", "zig"}}, want: 0.5},
+		{name: "all tags", signal: TagOverlap{}, candidate: Candidate{Tags: []string{"go"}}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signal.Value(tt.candidate, viewer); !closeTo(got, tt.want) {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankOrder(t *testing.T) {
+	r := New(
+		WeightedSignal{Signal: Recency{HalfLife: time.Hour}, Weight: 4},
+		WeightedSignal{Signal: Comments{}, Weight: 1},
+	)
+
+	candidates := []Candidate{
+		{PostID: 1, CreatedAt: now.Add(-2 * time.Hour)},
+		{PostID: 2, CreatedAt: now},
+		{PostID: 3, CreatedAt: now.Add(-2 * time.Hour), Comments: 100},
+		// ties with 5, the newest comes first
+		{PostID: 4, CreatedAt: now.Add(-3 * time.Hour)},
+		{PostID: 5, CreatedAt: now.Add(-3 * time.Hour)},
+		{PostID: 6, CreatedAt: now.Add(-time.Hour)},
+	}
+
+	ranked := r.Rank(candidates, Viewer{Now: now})
+
+	got := make([]int64, len(ranked))
+	for i, rk := range ranked {
+		got[i] = candidates[rk.Index].PostID
+	}
+
+	want := []int64{3, 2, 6, 1, 5, 4}
+	if !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+
+	for i := 1; i < len(ranked); i++ {
+		if ranked[i].Score > ranked[i-1].Score {
+			t.Errorf("score %v at %d is above %v at %d", ranked[i].Score, i, ranked[i-1].Score, i-1)
+		}
+	}
+}
+
+func TestRankIsDeterministic(t *testing.T) {
+	candidates := []Candidate{
+		{PostID: 1, CreatedAt: now, Tags: []string{"go"}},
+		{PostID: 2, CreatedAt: now, Tags: []string{"go"}},
+		{PostID: 3, CreatedAt: now.Add(-time.Minute), Likes: 2},
+		{PostID: 4, CreatedAt: now, Interactions: 1},
+	}
+	viewer := Viewer{Now: now, Tags: map[string]int{"go": 1}}
+
+	first := Default().Rank(candidates, viewer)
+
+	reversed := slices.Clone(candidates)
+	slices.Reverse(reversed)
+	second := Default().Rank(reversed, viewer)
+
+	for i := range first {
+		a, b := candidates[first[i].Index], reversed[second[i].Index]
+		if a.PostID != b.PostID {
+			t.Fatalf("post %d at %d, then post %d once the candidates are reversed", a.PostID, i, b.PostID)
+		}
+	}
+}
+
+func TestExplanation(t *testing.T) {
+	r := Default()
+
+	candidate := Candidate{
+		PostID:       1,
+		CreatedAt:    now.Add(-12 * time.Hour),
+		Tags:         []string{"go", "sql"},
+		Comments:     3,
+		Likes:        1,
+		Interactions: 7,
+	}
+	viewer := Viewer{Now: now, Tags: map[string]int{"go": 2}}
+
+	ranked := r.Rank([]Candidate{candidate}, viewer)
+	e := ranked[0].Explanation
+
+	want := []Contribution{
+		{Signal: "recency", Value: 0.5, Weight: 4, Score: 2},
+		{Signal: "comments", Value: math.Log(4), Weight: 1, Score: math.Log(4)},
+		{Signal: "likes", Value: math.Log(2), Weight: 1, Score: math.Log(2)},
+		{Signal: "author_affinity", Value: math.Log(8), Weight: 2, Score: 2 * math.Log(8)},
+		{Signal: "tag_overlap", Value: 0.5, Weight: 2, Score: 1},
+	}
+
+	if len(e.Signals) != len(want) {
+		t.Fatalf("%d signals, want %d", len(e.Signals), len(want))
+	}
+
+	var total float64
+	for i, c := range e.Signals {
+		w := want[i]
+		if c.Signal != w.Signal || !closeTo(c.Value, w.Value) || c.Weight != w.Weight || !closeTo(c.Score, w.Score) {
+			t.Errorf("signal %d = %+v, want %+v", i, c, w)
+		}
+		total += w.Score
+	}
+
+	if !closeTo(e.Score, total) {
+		t.Errorf("score = %v, want the sum of the signals %v", e.Score, total)
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
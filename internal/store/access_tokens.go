@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PersonalAccessToken lets scripts call the API on behalf of a user with a
+// limited set of scopes. Only the hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expiry"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type PersonalAccessTokenStore struct {
+	db *sql.DB
+}
+
+// lastUsedResolution avoids writing last_used_at on every single request
+const lastUsedResolution = time.Minute
+
+func (s *PersonalAccessTokenStore) Create(ctx context.Context, token string, pat *PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		pat.UserID,
+		pat.Name,
+		hashToken(token),
+		pq.Array(pat.Scopes),
+		pat.Expiry,
+	).Scan(
+		&pat.ID,
+		&pat.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetByToken returns the unexpired token and records that it was used.
+func (s *PersonalAccessTokenStore) GetByToken(ctx context.Context, token string) (*PersonalAccessToken, error) {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = CASE
+			WHEN last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $2) THEN NOW()
+			ELSE last_used_at
+		END
+		WHERE token = $1 AND (expiry IS NULL OR expiry > NOW())
+		RETURNING id, user_id, name, scopes, expiry, last_used_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	pat, err := scanPersonalAccessToken(s.db.QueryRowContext(ctx, query, hashToken(token), lastUsedResolution.Seconds()))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return pat, nil
+}
+
+func (s *PersonalAccessTokenStore) GetByUserID(ctx context.Context, userID int64) ([]PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, scopes, expiry, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		pat, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *pat)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (s *PersonalAccessTokenStore) Delete(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPersonalAccessToken(row rowScanner) (*PersonalAccessToken, error) {
+	var expiry, lastUsedAt sql.NullTime
+	pat := &PersonalAccessToken{}
+
+	err := row.Scan(
+		&pat.ID,
+		&pat.UserID,
+		&pat.Name,
+		pq.Array(&pat.Scopes),
+		&expiry,
+		&lastUsedAt,
+		&pat.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiry.Valid {
+		pat.Expiry = &expiry.Time
+	}
+	if lastUsedAt.Valid {
+		pat.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return pat, nil
+}
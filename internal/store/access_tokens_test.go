@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPersonalAccessTokenHasScope(t *testing.T) {
+	pat := &PersonalAccessToken{Scopes: []string{"posts:read", "feed:read"}}
+
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"posts:read", true},
+		{"feed:read", true},
+		{"posts:write", false},
+		{"posts", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := pat.HasScope(tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestPersonalAccessTokenLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	tokens := &PersonalAccessTokenStore{db}
+	ctx := context.Background()
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	pat := &PersonalAccessToken{UserID: alice.ID, Name: "script", Scopes: []string{"posts:read"}}
+	if err := tokens.Create(ctx, "gsp_script", pat); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tokens.Create(ctx, "gsp_other", &PersonalAccessToken{UserID: alice.ID, Name: "script", Scopes: []string{"posts:read"}}); err != ErrConflict {
+		t.Fatalf("create a second token with the same name: got %v, want ErrConflict", err)
+	}
+
+	found, err := tokens.GetByToken(ctx, "gsp_script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != pat.ID || found.UserID != alice.ID || !found.HasScope("posts:read") {
+		t.Fatalf("found token = %+v", found)
+	}
+	if found.LastUsedAt == nil {
+		t.Fatal("last_used_at wasn't recorded")
+	}
+
+	if _, err := tokens.GetByToken(ctx, "gsp_unknown"); err != ErrNotFound {
+		t.Fatalf("unknown token: got %v, want ErrNotFound", err)
+	}
+
+	if err := tokens.Delete(ctx, bob.ID, pat.ID); err != ErrNotFound {
+		t.Fatalf("delete the token of another user: got %v, want ErrNotFound", err)
+	}
+
+	if err := tokens.Delete(ctx, alice.ID, pat.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.GetByToken(ctx, "gsp_script"); err != ErrNotFound {
+		t.Fatalf("deleted token: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestPersonalAccessTokenExpiry(t *testing.T) {
+	db := newTestDB(t)
+	tokens := &PersonalAccessTokenStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "expiring")
+
+	expiry := time.Now().Add(-time.Minute)
+	if err := tokens.Create(ctx, "gsp_expired", &PersonalAccessToken{UserID: user.ID, Name: "expired", Scopes: []string{"posts:read"}, Expiry: &expiry}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.GetByToken(ctx, "gsp_expired"); err != ErrNotFound {
+		t.Fatalf("expired token: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestRevokeAllDeletesPersonalAccessTokens(t *testing.T) {
+	db := newTestDB(t)
+	tokens := &PersonalAccessTokenStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "compromised")
+
+	if err := tokens.Create(ctx, "gsp_leaked", &PersonalAccessToken{UserID: user.ID, Name: "leaked", Scopes: []string{"posts:write"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&RevokedTokenStore{db}).RevokeAll(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.GetByToken(ctx, "gsp_leaked"); err != ErrNotFound {
+		t.Fatalf("token after RevokeAll: got %v, want ErrNotFound", err)
+	}
+
+	list, err := tokens.GetByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("%d tokens listed after RevokeAll, want 0", len(list))
+	}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrBlocked = errors.New("one of the users blocked the other")
+
+// blockedQuery matches a block between the users $1 and $2 in either direction.
+const blockedQuery = `
+	SELECT 1 FROM user_blocks
+	WHERE (user_id = $1 AND blocked_id = $2) OR (user_id = $2 AND blocked_id = $1)
+`
+
+// RelatedUser is an entry of the block or mute list of a user.
+type RelatedUser struct {
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlockStore holds the blocks, which cut every interaction between two
+// users, and the mutes, which only keep a user out of the feed.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block also removes the follows and follow requests between the users.
+func (s *BlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		queries := []string{
+			`INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			`DELETE FROM followers WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`,
+			`DELETE FROM follow_requests WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)`,
+		}
+
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, userID, blockedID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	return s.delete(ctx, `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`, userID, blockedID)
+}
+
+func (s *BlockStore) GetBlocked(ctx context.Context, userID int64) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1
+		ORDER BY b.created_at DESC
+	`
+
+	return s.list(ctx, query, userID)
+}
+
+// IsBlocked tells whether either user blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT EXISTS (` + blockedQuery + `)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
+
+func (s *BlockStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	query := `INSERT INTO user_mutes (user_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	return s.delete(ctx, `DELETE FROM user_mutes WHERE user_id = $1 AND muted_id = $2`, userID, mutedID)
+}
+
+func (s *BlockStore) GetMuted(ctx context.Context, userID int64) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, m.created_at
+		FROM user_mutes m
+		JOIN users u ON u.id = m.muted_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at DESC
+	`
+
+	return s.list(ctx, query, userID)
+}
+
+func (s *BlockStore) delete(ctx context.Context, query string, userID, otherID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BlockStore) list(ctx context.Context, query string, userID int64) ([]RelatedUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RelatedUser{}
+	for rows.Next() {
+		var ru RelatedUser
+		err := rows.Scan(
+			&ru.User.ID,
+			&ru.User.Username,
+			&ru.User.DisplayName,
+			&ru.User.AvatarURL,
+			&ru.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, ru)
+	}
+
+	return users, rows.Err()
+}
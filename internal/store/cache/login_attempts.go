@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+type LoginAttemptStore struct {
+	rdb *redis.Client
+}
+
+// LoginLockExpTime is how long the lockout history of a key is remembered, so
+// repeated lockouts keep escalating instead of starting from scratch.
+const LoginLockExpTime = time.Hour * 24
+
+func failuresKey(key string) string {
+	return fmt.Sprintf("login-failures-%s", key)
+}
+
+func lockKey(key string) string {
+	return fmt.Sprintf("login-lock-%s", key)
+}
+
+func (s *LoginAttemptStore) Get(ctx context.Context, key string) (*store.LoginAttempt, error) {
+	failures, err := s.rdb.Get(ctx, failuresKey(key)).Int()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	lock, err := s.rdb.HGetAll(ctx, lockKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if failures == 0 && len(lock) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	attempt := &store.LoginAttempt{Key: key, Failures: failures}
+	if err := parseLock(lock, attempt); err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// RecordFailure relies on the key TTL to forget failures older than the window.
+func (s *LoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (*store.LoginAttempt, error) {
+	failures, err := s.rdb.Incr(ctx, failuresKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if failures == 1 {
+		if err := s.rdb.Expire(ctx, failuresKey(key), window).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	lock, err := s.rdb.HGetAll(ctx, lockKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &store.LoginAttempt{
+		Key:         key,
+		Failures:    int(failures),
+		LastFailure: time.Now(),
+	}
+	if err := parseLock(lock, attempt); err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (s *LoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, lockKey(key), "lockouts", 1)
+	pipe.HSet(ctx, lockKey(key), "locked_until", until.Unix())
+	pipe.Expire(ctx, lockKey(key), time.Until(until)+LoginLockExpTime)
+	pipe.Del(ctx, failuresKey(key))
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *LoginAttemptStore) Reset(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, failuresKey(key), lockKey(key)).Err()
+}
+
+func parseLock(lock map[string]string, attempt *store.LoginAttempt) error {
+	if v, ok := lock["lockouts"]; ok {
+		lockouts, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		attempt.Lockouts = lockouts
+	}
+
+	if v, ok := lock["locked_until"]; ok {
+		until, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		attempt.LockedUntil = time.Unix(until, 0)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ResponseStore caches the responses of the public endpoints, keyed by
+// their URL.
+type ResponseStore struct {
+	rdb *redis.Client
+}
+
+type Response struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (s *ResponseStore) Get(ctx context.Context, key string) (*Response, error) {
+	cacheKey := "response-" + key
+
+	data, err := s.rdb.Get(ctx, cacheKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (s *ResponseStore) Set(ctx context.Context, key string, response *Response, ttl time.Duration) error {
+	cacheKey := "response-" + key
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, data, ttl).Err()
+}
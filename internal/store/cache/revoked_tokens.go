@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RevokedTokenStore is a fast path in front of postgres. Both positive and
+// negative answers are cached, a revocation always overwrites the entry so a
+// cached "not revoked" can never hide it.
+type RevokedTokenStore struct {
+	rdb *redis.Client
+}
+
+const ValidAfterExpTime = time.Minute * 10
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, bool, error) {
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	revoked, err := s.rdb.Get(ctx, cacheKey).Bool()
+	if err == redis.Nil {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, err
+	}
+
+	return revoked, true, nil
+}
+
+func (s *RevokedTokenStore) SetRevoked(ctx context.Context, jti string, revoked bool, ttl time.Duration) error {
+	cacheKey := fmt.Sprintf("revoked-token-%s", jti)
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, revoked, ttl).Err()
+}
+
+// Note: the cut-off is kept in microseconds, the key changed from the one
+// holding seconds so those are not read as microseconds.
+func (s *RevokedTokenStore) GetValidAfter(ctx context.Context, userID int64) (time.Time, bool, error) {
+	cacheKey := fmt.Sprintf("tokens-valid-after-us-%d", userID)
+
+	micros, err := s.rdb.Get(ctx, cacheKey).Int64()
+	if err == redis.Nil {
+		return time.Time{}, false, nil
+	} else if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return time.UnixMicro(micros), true, nil
+}
+
+func (s *RevokedTokenStore) SetValidAfter(ctx context.Context, userID int64, validAfter time.Time) error {
+	cacheKey := fmt.Sprintf("tokens-valid-after-us-%d", userID)
+
+	return s.rdb.SetEX(ctx, cacheKey, validAfter.UnixMicro(), ValidAfterExpTime).Err()
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/mayankpatidar275/go-social/internal/store"
@@ -11,11 +12,39 @@ type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	LoginAttempts interface {
+		Get(context.Context, string) (*store.LoginAttempt, error)
+		RecordFailure(ctx context.Context, key string, window time.Duration) (*store.LoginAttempt, error)
+		Lock(ctx context.Context, key string, until time.Time) error
+		Reset(context.Context, string) error
+	}
+	RevokedTokens interface {
+		IsRevoked(ctx context.Context, jti string) (revoked bool, found bool, err error)
+		SetRevoked(ctx context.Context, jti string, revoked bool, ttl time.Duration) error
+		GetValidAfter(ctx context.Context, userID int64) (validAfter time.Time, found bool, err error)
+		SetValidAfter(ctx context.Context, userID int64, validAfter time.Time) error
+	}
+	Timelines interface {
+		Get(ctx context.Context, userID int64, after *store.Cursor, limit int) ([]store.Cursor, bool, error)
+		Set(ctx context.Context, userID int64, positions []store.Cursor) error
+		Push(ctx context.Context, userIDs []int64, position store.Cursor) error
+		Remove(ctx context.Context, userIDs []int64, position store.Cursor) error
+		Delete(context.Context, int64) error
+	}
+	Responses interface {
+		Get(ctx context.Context, key string) (*Response, error)
+		Set(ctx context.Context, key string, response *Response, ttl time.Duration) error
 	}
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rbd},
+		Users:         &UserStore{rdb: rbd},
+		LoginAttempts: &LoginAttemptStore{rdb: rbd},
+		RevokedTokens: &RevokedTokenStore{rdb: rbd},
+		Timelines:     &TimelineStore{rdb: rbd},
+		Responses:     &ResponseStore{rdb: rbd},
 	}
 }
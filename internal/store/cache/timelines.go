@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+// TimelineStore caches the newest entries of the home timelines. A timeline
+// is a sorted set whose members all have the same score and sort by their
+// position, so the pages are read by lexicographic range. The cached entries
+// are always the newest of the timeline, posts are only pushed to timelines
+// that are already cached, otherwise the cache is filled from postgres.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+const (
+	TimelineExpTime = time.Hour * 24
+	// TimelineSize is the number of entries kept per cached timeline
+	TimelineSize = 800
+)
+
+// pushTimeline adds ARGV[1] to the cached timelines KEYS, trimming them to
+// ARGV[2] entries
+var pushTimeline = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("EXISTS", key) == 1 then
+		redis.call("ZADD", key, 0, ARGV[1])
+		redis.call("ZREMRANGEBYRANK", key, 0, -tonumber(ARGV[2]) - 1)
+	end
+end
+return 0
+`)
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+// timelineMember sorts the positions like the feed, newest last
+func timelineMember(c store.Cursor) string {
+	return fmt.Sprintf("%020d:%020d", c.CreatedAt.UnixNano(), c.ID)
+}
+
+func parseTimelineMember(member string) (store.Cursor, error) {
+	nanos, id, ok := strings.Cut(member, ":")
+	if !ok {
+		return store.Cursor{}, fmt.Errorf("invalid timeline member: %q", member)
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return store.Cursor{}, err
+	}
+	postID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return store.Cursor{}, err
+	}
+
+	return store.Cursor{CreatedAt: time.Unix(0, n), ID: postID}, nil
+}
+
+// Get returns up to limit entries of the cached timeline of the user
+// following after, newest first. found is false when the timeline isn't
+// cached.
+func (s *TimelineStore) Get(ctx context.Context, userID int64, after *store.Cursor, limit int) ([]store.Cursor, bool, error) {
+	cacheKey := timelineKey(userID)
+
+	max := "+"
+	if after != nil {
+		max = "(" + timelineMember(*after)
+	}
+
+	pipe := s.rdb.Pipeline()
+	exists := pipe.Exists(ctx, cacheKey)
+	members := pipe.ZRevRangeByLex(ctx, cacheKey, &redis.ZRangeBy{Min: "-", Max: max, Count: int64(limit)})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, false, err
+	}
+
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+
+	var positions []store.Cursor
+	for _, member := range members.Val() {
+		c, err := parseTimelineMember(member)
+		if err != nil {
+			return nil, false, err
+		}
+		positions = append(positions, c)
+	}
+
+	return positions, true, nil
+}
+
+// Set caches the newest entries of the timeline of the user, replacing
+// whatever was cached.
+func (s *TimelineStore) Set(ctx context.Context, userID int64, positions []store.Cursor) error {
+	cacheKey := timelineKey(userID)
+
+	if len(positions) == 0 {
+		return s.rdb.Del(ctx, cacheKey).Err()
+	}
+
+	members := make([]*redis.Z, len(positions))
+	for i, c := range positions {
+		members[i] = &redis.Z{Member: timelineMember(c)}
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, cacheKey)
+		pipe.ZAdd(ctx, cacheKey, members...)
+		pipe.Expire(ctx, cacheKey, TimelineExpTime)
+		return nil
+	})
+	return err
+}
+
+// Push adds the post to the cached timelines of the users.
+func (s *TimelineStore) Push(ctx context.Context, userIDs []int64, position store.Cursor) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = timelineKey(id)
+	}
+
+	return pushTimeline.Run(ctx, s.rdb, keys, timelineMember(position), TimelineSize).Err()
+}
+
+// Remove takes the post out of the cached timelines of the users.
+func (s *TimelineStore) Remove(ctx context.Context, userIDs []int64, position store.Cursor) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	member := timelineMember(position)
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pipe.ZRem(ctx, timelineKey(id), member)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *TimelineStore) Delete(ctx context.Context, userID int64) error {
+	return s.rdb.Del(ctx, timelineKey(userID)).Err()
+}
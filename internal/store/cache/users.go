@@ -16,6 +16,15 @@ type UserStore struct {
 
 const UserExpTime = time.Minute
 
+// cachedUser also keeps the moderation state, the user doesn't marshal it as
+// it must not be shown to other users.
+type cachedUser struct {
+	*store.User
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	BannedAt         *time.Time `json:"banned_at,omitempty"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+}
+
 func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
 	cacheKey := fmt.Sprintf("user-%d", userID)
 
@@ -26,24 +35,40 @@ func (s *UserStore) Get(ctx context.Context, userID int64) (*store.User, error)
 		return nil, err
 	}
 
-	var user store.User
+	cached := cachedUser{User: &store.User{}}
 	if data != "" {
-		err := json.Unmarshal([]byte(data), &user)
+		err := json.Unmarshal([]byte(data), &cached)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &user, nil
+	user := cached.User
+	user.SuspendedUntil = cached.SuspendedUntil
+	user.BannedAt = cached.BannedAt
+	user.ModerationReason = cached.ModerationReason
+
+	return user, nil
 }
 
 func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 	cacheKey := fmt.Sprintf("user-%d", user.ID)
 
-	json, err := json.Marshal(user)
+	json, err := json.Marshal(cachedUser{
+		User:             user,
+		SuspendedUntil:   user.SuspendedUntil,
+		BannedAt:         user.BannedAt,
+		ModerationReason: user.ModerationReason,
+	})
 	if err != nil {
 		return err
 	}
 
 	return s.rdb.SetEX(ctx, cacheKey, json, UserExpTime).Err()
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID int64) error {
+	cacheKey := fmt.Sprintf("user-%d", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankpatidar275/go-social/internal/store"
+)
+
+func TestUserModerationStateIsOnlyCached(t *testing.T) {
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := &store.User{
+		ID:               1,
+		Username:         "suspended",
+		SuspendedUntil:   &until,
+		ModerationReason: "spam",
+	}
+
+	public, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"suspended_until", "banned_at", "moderation_reason"} {
+		if strings.Contains(string(public), field) {
+			t.Errorf("the user marshals %s: %s", field, public)
+		}
+	}
+
+	data, err := json.Marshal(cachedUser{
+		User:             user,
+		SuspendedUntil:   user.SuspendedUntil,
+		ModerationReason: user.ModerationReason,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached := cachedUser{User: &store.User{}}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatal(err)
+	}
+
+	if cached.User.Username != user.Username {
+		t.Errorf("username = %q, want %q", cached.User.Username, user.Username)
+	}
+	if cached.SuspendedUntil == nil || !cached.SuspendedUntil.Equal(until) {
+		t.Errorf("suspended until = %v, want %v", cached.SuspendedUntil, until)
+	}
+	if cached.BannedAt != nil {
+		t.Errorf("banned at = %v, want nil", cached.BannedAt)
+	}
+	if cached.ModerationReason != user.ModerationReason {
+		t.Errorf("moderation reason = %q, want %q", cached.ModerationReason, user.ModerationReason)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Comment struct {
@@ -27,19 +28,24 @@ type CommentStore struct {
 
 // }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID leaves out the comments of users in a block with the viewer.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id 
 		FROM comments c
 		JOIN users on users.id = c.user_id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1 AND users.deleted_at IS NULL AND users.banned_at IS NULL AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = c.user_id) OR (b.user_id = c.user_id AND b.blocked_id = $2)
+			)
 		ORDER BY c.created_at DESC;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -58,10 +64,71 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 	return comments, nil
 }
 
+// List returns a page of the comments on the post, newest first, leaving
+// out the comments of users in a block with the viewer.
+func (s *CommentStore) List(ctx context.Context, postID, viewerID int64, q CursorQuery) ([]Comment, PageInfo, error) {
+	cond, order, keysetArgs := q.keyset(true, "c.created_at", "c.id", 4)
+
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id
+		FROM comments c
+		JOIN users on users.id = c.user_id
+		WHERE c.post_id = $1 AND users.deleted_at IS NULL AND users.banned_at IS NULL AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = c.user_id) OR (b.user_id = c.user_id AND b.blocked_id = $2)
+			) AND
+			` + cond + `
+		ORDER BY ` + order + `
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{postID, viewerID, q.Limit + 1}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	var positions []Cursor
+	for rows.Next() {
+		var (
+			c         Comment
+			createdAt time.Time
+		)
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &createdAt, &c.User.Username, &c.User.ID)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+		// same format as scanning the timestamp into the string
+		c.CreatedAt = createdAt.Format(time.RFC3339Nano)
+		comments = append(comments, c)
+		positions = append(positions, Cursor{CreatedAt: createdAt, ID: c.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	comments, info := paginate(comments, positions, q)
+	return comments, info, nil
+}
+
+// Create returns ErrNotFound when the post doesn't exist and ErrBlocked when
+// the commenter and the author of the post are in a block.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
 		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
+		SELECT p.id, $2, $3
+		FROM posts p
+		WHERE p.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.user_id = p.user_id AND b.blocked_id = $2) OR (b.user_id = $2 AND b.blocked_id = p.user_id)
+		)
 		RETURNING id, created_at
 	`
 
@@ -73,7 +140,28 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	).Scan(&comment.ID, &comment.CreatedAt)
 
 	if err != nil {
-		return err
+		switch err {
+		case sql.ErrNoRows:
+			return s.createRefusal(ctx, comment.PostID)
+		default:
+			return err
+		}
 	}
 	return nil
 }
+
+// createRefusal tells why no comment was inserted on the post: it is missing
+// or, otherwise, a block prevented it.
+func (s *CommentStore) createRefusal(ctx context.Context, postID int64) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1)`, postID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return ErrBlocked
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommentCreate(t *testing.T) {
+	db := newTestDB(t)
+	comments := &CommentStore{db}
+	blocks := &BlockStore{db}
+	ctx := context.Background()
+
+	author := createTestUser(t, db, "author")
+	commenter := createTestUser(t, db, "commenter")
+	blocked := createTestUser(t, db, "blocked")
+
+	postID := createTestPost(t, db, author.ID, time.Now())
+
+	if err := blocks.Block(ctx, author.ID, blocked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		postID int64
+		userID int64
+		want   error
+	}{
+		{name: "allowed", postID: postID, userID: commenter.ID},
+		{name: "missing post", postID: postID + 1000, userID: commenter.ID, want: ErrNotFound},
+		{name: "blocked by the author", postID: postID, userID: blocked.ID, want: ErrBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := &Comment{PostID: tt.postID, UserID: tt.userID, Content: "comment"}
+
+			if err := comments.Create(ctx, comment); err != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && comment.ID == 0 {
+				t.Fatal("the comment has no id")
+			}
+		})
+	}
+}
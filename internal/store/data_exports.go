@@ -0,0 +1,398 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is an archive of everything stored about a user, downloaded
+// with a token mailed to them.
+type DataExport struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Status      string     `json:"status"`
+	FilePath    string     `json:"-"`
+	Expiry      time.Time  `json:"expiry"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Connection is one side of a follow relationship.
+type Connection struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// PersonalData is what goes into a data export.
+type PersonalData struct {
+	User      *User
+	Posts     []Post
+	Comments  []Comment
+	Followers []Connection
+	Following []Connection
+	Sessions  []Session
+}
+
+type DataExportStore struct {
+	db *sql.DB
+}
+
+// Create registers a pending export. Only one export can be in progress per
+// user, ErrConflict is returned while another one is pending. The ready,
+// unexpired export of the user is returned in place of a new one, its link was
+// mailed already. Otherwise a new export is refused with ErrThrottled while
+// the last one is younger than cooldown.
+func (s *DataExportStore) Create(ctx context.Context, token string, export *DataExport, cooldown time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// serialize the requests of the user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, export.UserID); err != nil {
+			return err
+		}
+
+		query := `
+			SELECT id, user_id, status, file_path, expiry, created_at, completed_at
+			FROM data_exports
+			WHERE user_id = $1 AND status <> $2 AND expiry > NOW()
+			ORDER BY created_at DESC
+			LIMIT 1
+		`
+
+		current, err := scanDataExport(tx.QueryRowContext(ctx, query, export.UserID, DataExportFailed))
+		switch err {
+		case nil:
+			if current.Status == DataExportPending {
+				return ErrConflict
+			}
+			*export = *current
+			return nil
+		case sql.ErrNoRows:
+		default:
+			return err
+		}
+
+		var lastCreated sql.NullTime
+		query = `SELECT MAX(created_at) FROM data_exports WHERE user_id = $1`
+		if err := tx.QueryRowContext(ctx, query, export.UserID).Scan(&lastCreated); err != nil {
+			return err
+		}
+		if lastCreated.Valid && time.Since(lastCreated.Time) < cooldown {
+			return ErrThrottled
+		}
+
+		query = `
+			INSERT INTO data_exports (user_id, token, status, expiry)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, status, created_at
+		`
+
+		return tx.QueryRowContext(
+			ctx,
+			query,
+			export.UserID,
+			hashToken(token),
+			DataExportPending,
+			export.Expiry,
+		).Scan(
+			&export.ID,
+			&export.Status,
+			&export.CreatedAt,
+		)
+	})
+}
+
+// Complete marks the export ready to download from filePath until expiry.
+func (s *DataExportStore) Complete(ctx context.Context, id int64, filePath string, expiry time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = $1, file_path = $2, expiry = $3, completed_at = NOW()
+		WHERE id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, DataExportReady, filePath, expiry, id)
+	return err
+}
+
+func (s *DataExportStore) Fail(ctx context.Context, id int64) error {
+	query := `UPDATE data_exports SET status = $1, completed_at = NOW() WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, DataExportFailed, id)
+	return err
+}
+
+// GetByToken returns the ready, unexpired export the token was issued for.
+func (s *DataExportStore) GetByToken(ctx context.Context, token string) (*DataExport, error) {
+	query := `
+		SELECT id, user_id, status, file_path, expiry, created_at, completed_at
+		FROM data_exports
+		WHERE token = $1 AND status = $2 AND expiry > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	export, err := scanDataExport(s.db.QueryRowContext(ctx, query, hashToken(token), DataExportReady))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+func scanDataExport(row rowScanner) (*DataExport, error) {
+	var completedAt sql.NullTime
+	export := &DataExport{}
+
+	err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.FilePath,
+		&export.Expiry,
+		&export.CreatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+
+	return export, nil
+}
+
+// FailStale marks failed the exports still pending since before, their build
+// died with the process. It returns the number of failed exports.
+func (s *DataExportStore) FailStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		UPDATE data_exports SET status = $1, completed_at = NOW()
+		WHERE status = $2 AND created_at < $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, DataExportFailed, DataExportPending, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteExpired removes the expired exports and returns the files they had.
+func (s *DataExportStore) DeleteExpired(ctx context.Context) ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE expiry < NOW()
+		RETURNING file_path
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExportPaths(rows)
+}
+
+func deleteDataExports(ctx context.Context, tx *sql.Tx, userIDs []int64) ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE user_id = ANY($1)
+		RETURNING file_path
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExportPaths(rows)
+}
+
+func scanExportPaths(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, rows.Err()
+}
+
+// CollectPersonalData loads everything stored about the user for an export.
+func (s *DataExportStore) CollectPersonalData(ctx context.Context, userID int64) (*PersonalData, error) {
+	users := &UserStore{s.db}
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &PersonalData{User: user}
+
+	if data.Posts, err = s.getPosts(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if data.Comments, err = s.getComments(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	followersQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at DESC
+	`
+	if data.Followers, err = s.getConnections(ctx, followersQuery, userID); err != nil {
+		return nil, err
+	}
+
+	followingQuery := `
+		SELECT u.id, u.username, f.created_at
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at DESC
+	`
+	if data.Following, err = s.getConnections(ctx, followingQuery, userID); err != nil {
+		return nil, err
+	}
+
+	sessions := &SessionStore{s.db}
+	if data.Sessions, err = sessions.GetByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *DataExportStore) getPosts(ctx context.Context, userID int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, created_at, updated_at, tags, version
+		FROM posts
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+func (s *DataExportStore) getComments(ctx context.Context, userID int64) ([]Comment, error) {
+	query := `
+		SELECT id, post_id, user_id, content, created_at
+		FROM comments
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s *DataExportStore) getConnections(ctx context.Context, query string, userID int64) ([]Connection, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := []Connection{}
+	for rows.Next() {
+		var c Connection
+		if err := rows.Scan(&c.UserID, &c.Username, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+
+	return connections, rows.Err()
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCreateDataExport(t *testing.T) {
+	db := newTestDB(t)
+	exports := &DataExportStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "exporter")
+
+	first := &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}
+	if err := exports.Create(ctx, "first", first, 0); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != DataExportPending {
+		t.Fatalf("status = %q, want %q", first.Status, DataExportPending)
+	}
+
+	if err := exports.Create(ctx, "second", &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}, 0); err != ErrConflict {
+		t.Fatalf("create while pending: got %v, want ErrConflict", err)
+	}
+
+	if err := exports.Complete(ctx, first.ID, "/exports/first.zip", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	reused := &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}
+	if err := exports.Create(ctx, "third", reused, 0); err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != first.ID || reused.Status != DataExportReady {
+		t.Fatalf("create while ready = %+v, want export %d", reused, first.ID)
+	}
+
+	// the token of the reused export didn't change
+	if _, err := exports.GetByToken(ctx, "third"); err != ErrNotFound {
+		t.Fatalf("token of the reused export: got %v, want ErrNotFound", err)
+	}
+	if _, err := exports.GetByToken(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateDataExportCooldown(t *testing.T) {
+	db := newTestDB(t)
+	exports := &DataExportStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "retrying")
+
+	failed := &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}
+	if err := exports.Create(ctx, "failed", failed, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := exports.Fail(ctx, failed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exports.Create(ctx, "retry", &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}, time.Hour); err != ErrThrottled {
+		t.Fatalf("create within the cooldown: got %v, want ErrThrottled", err)
+	}
+
+	if err := exports.Create(ctx, "retry", &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}, 0); err != nil {
+		t.Fatalf("create after the cooldown: %v", err)
+	}
+}
+
+func TestFailStaleDataExports(t *testing.T) {
+	db := newTestDB(t)
+	exports := &DataExportStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "crashed")
+
+	stale := &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}
+	if err := exports.Create(ctx, "stale", stale, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := exports.FailStale(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 0 {
+		t.Fatalf("failed %d recent exports, want 0", failed)
+	}
+
+	failed, err = exports.FailStale(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Fatalf("failed %d stale exports, want 1", failed)
+	}
+
+	if err := exports.Create(ctx, "retry", &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}, 0); err != nil {
+		t.Fatalf("create after the stale export failed: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SoftDelete hides the user, and their posts and comments, until they restore
+// the account or it gets purged.
+func (s *UserStore) SoftDelete(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Restore undoes a soft delete done less than window ago.
+func (s *UserStore) Restore(ctx context.Context, userID int64, window time.Duration) error {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at > $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, time.Now().Add(-window))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted hard deletes the users soft deleted more than window ago with
+// their posts, the comments on those posts and their own comments. Followers
+// and the other user data go with the ON DELETE CASCADE constraints. It
+// returns the number of deleted users and the archives of their data exports,
+// removing the files is left to the caller. Their usernames stay held for
+// usernameHold.
+func (s *UserStore) PurgeDeleted(ctx context.Context, window, usernameHold time.Duration) (int64, []string, error) {
+	var purged int64
+	var exportPaths []string
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM users WHERE deleted_at < $1 FOR UPDATE`, time.Now().Add(-window))
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := s.holdUsernames(ctx, tx, ids, usernameHold); err != nil {
+			return err
+		}
+
+		// the rows are the only record of the archives, they would go with the users
+		exportPaths, err = deleteDataExports(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		queries := []string{
+			`DELETE FROM comments WHERE user_id = ANY($1) OR post_id IN (SELECT id FROM posts WHERE user_id = ANY($1))`,
+			`DELETE FROM posts WHERE user_id = ANY($1)`,
+			`DELETE FROM user_invitations WHERE user_id = ANY($1)`,
+			`DELETE FROM users WHERE id = ANY($1)`,
+		}
+
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+				return err
+			}
+		}
+
+		purged = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return purged, exportPaths, nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPurgeDeletedReturnsTheExportArchives(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	exports := &DataExportStore{db}
+	ctx := context.Background()
+
+	gone := createTestUser(t, db, "gone")
+	kept := createTestUser(t, db, "kept")
+
+	for _, user := range []*User{gone, kept} {
+		export := &DataExport{UserID: user.ID, Expiry: time.Now().Add(time.Hour)}
+		if err := exports.Create(ctx, user.Username+"-token", export, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := exports.Complete(ctx, export.ID, "/exports/"+user.Username+".zip", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := users.SoftDelete(ctx, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, paths, err := users.PurgeDeleted(ctx, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d users, want 1", purged)
+	}
+	if len(paths) != 1 || paths[0] != "/exports/gone.zip" {
+		t.Fatalf("export archives = %v, want the one of the purged user", paths)
+	}
+
+	if _, err := exports.GetByToken(ctx, "kept-token"); err != nil {
+		t.Fatalf("the export of another user went: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CreateEmailChange stores newEmail as the pending address of the user until
+// it is confirmed with the token. It replaces any previous pending change.
+func (s *UserStore) CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var taken bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, newEmail).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateEmail
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO email_changes (token, user_id, new_email, expiry) VALUES ($1, $2, $3, $4)`
+
+		_, err := tx.ExecContext(ctx, query, token, userID, newEmail, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ConfirmEmailChange swaps the email of the user the token was sent for. It
+// returns the user with the new address.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	var userID int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM email_changes
+			WHERE token = $1
+			RETURNING user_id, new_email, expiry
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var (
+			newEmail string
+			expiry   time.Time
+		)
+		if err := tx.QueryRowContext(ctx, query, token).Scan(&userID, &newEmail, &expiry); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		query = `UPDATE users SET email = $1, version = version + 1 WHERE id = $2`
+
+		if _, err := tx.ExecContext(ctx, query, newEmail, userID); err != nil {
+			return userConstraintError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(ctx, userID)
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+type FollowListEntry struct {
+	User       User      `json:"user"`
+	FollowedAt time.Time `json:"followed_at"`
+	// FollowsYou tells whether the user follows the viewer
+	FollowsYou bool `json:"follows_you"`
+}
+
+// GetFollowers returns a page of the followers of the user, newest first,
+// leaving out the users in a block with the viewer.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, q CursorQuery) ([]FollowListEntry, PageInfo, error) {
+	return s.list(ctx, "f.user_id", "f.follower_id", userID, viewerID, q)
+}
+
+// GetFollowing returns a page of the users the user follows, newest first,
+// leaving out the users in a block with the viewer.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, q CursorQuery) ([]FollowListEntry, PageInfo, error) {
+	return s.list(ctx, "f.follower_id", "f.user_id", userID, viewerID, q)
+}
+
+// list pages through the followers rows where ownerColumn is the user,
+// listing the users of otherColumn.
+func (s *FollowerStore) list(ctx context.Context, ownerColumn, otherColumn string, userID, viewerID int64, q CursorQuery) ([]FollowListEntry, PageInfo, error) {
+	cond, order, keysetArgs := q.keyset(true, "f.created_at", otherColumn, 4)
+
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_url, u.is_private, f.created_at,
+			EXISTS (SELECT 1 FROM followers y WHERE y.user_id = $2 AND y.follower_id = u.id)
+		FROM followers f
+		JOIN users u ON u.id = ` + otherColumn + `
+		WHERE ` + ownerColumn + ` = $1 AND u.deleted_at IS NULL AND u.banned_at IS NULL AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $2 AND b.blocked_id = u.id) OR (b.user_id = u.id AND b.blocked_id = $2)
+			) AND
+			` + cond + `
+		ORDER BY ` + order + `
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{userID, viewerID, q.Limit + 1}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	entries := []FollowListEntry{}
+	var positions []Cursor
+	for rows.Next() {
+		var e FollowListEntry
+		err := rows.Scan(
+			&e.User.ID,
+			&e.User.Username,
+			&e.User.DisplayName,
+			&e.User.AvatarURL,
+			&e.User.IsPrivate,
+			&e.FollowedAt,
+			&e.FollowsYou,
+		)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+		entries = append(entries, e)
+		positions = append(positions, Cursor{CreatedAt: e.FollowedAt, ID: e.User.ID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	entries, info := paginate(entries, positions, q)
+	return entries, info, nil
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFollowCounts(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	followers := &FollowerStore{db}
+	posts := &PostStore{db}
+	ctx := context.Background()
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+
+	for _, follow := range [][2]*User{{bob, alice}, {carol, alice}, {alice, bob}} {
+		if err := followers.Follow(ctx, follow[0].ID, follow[1].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createTestPost(t, db, alice.ID, time.Now())
+	deleted := createTestPost(t, db, alice.ID, time.Now())
+	if err := posts.Delete(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := followers.Unfollow(ctx, carol.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user                                    *User
+		wantFollowers, wantFollowing, wantPosts int
+	}{
+		{alice, 1, 1, 1},
+		{bob, 1, 1, 0},
+		{carol, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		user, err := users.GetByID(ctx, tt.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := [3]int{user.FollowersCount, user.FollowingCount, user.PostsCount}
+		want := [3]int{tt.wantFollowers, tt.wantFollowing, tt.wantPosts}
+		if got != want {
+			t.Errorf("%s: followers, following, posts = %v, want %v", tt.user.Username, got, want)
+		}
+	}
+}
+
+func TestFollowCountsConcurrentFollowBack(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	followers := &FollowerStore{db}
+	ctx := context.Background()
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	// follows in opposite directions lock the same two rows
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, follow := range [][2]*User{{alice, bob}, {bob, alice}} {
+		wg.Add(1)
+		go func(followerID, userID int64) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := followers.Follow(ctx, followerID, userID); err != nil {
+					errs <- err
+					return
+				}
+				if err := followers.Unfollow(ctx, followerID, userID); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(follow[0].ID, follow[1].ID)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	for _, u := range []*User{alice, bob} {
+		user, err := users.GetByID(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.FollowersCount != 0 || user.FollowingCount != 0 {
+			t.Fatalf("%s: followers %d, following %d, want 0", u.Username, user.FollowersCount, user.FollowingCount)
+		}
+	}
+}
+
+func TestGetFollowers(t *testing.T) {
+	db := newTestDB(t)
+	followers := &FollowerStore{db}
+	ctx := context.Background()
+
+	star := createTestUser(t, db, "star")
+	viewer := createTestUser(t, db, "viewer")
+	fan1 := createTestUser(t, db, "fan1")
+	fan2 := createTestUser(t, db, "fan2")
+	fan3 := createTestUser(t, db, "fan3")
+	banned := createTestUser(t, db, "banned")
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, fan := range []*User{fan1, fan2, fan3, banned} {
+		if err := followers.Follow(ctx, fan.ID, star.ID); err != nil {
+			t.Fatal(err)
+		}
+		followedAt := base.Add(time.Duration(i) * time.Minute)
+		if _, err := db.Exec(`UPDATE followers SET created_at = $1 WHERE user_id = $2 AND follower_id = $3`, followedAt, star.ID, fan.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE users SET banned_at = NOW() WHERE id = $1`, banned.ID); err != nil {
+		t.Fatal(err)
+	}
+	// fan2 follows the viewer
+	if err := followers.Follow(ctx, fan2.ID, viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	page, info, err := followers.GetFollowers(ctx, star.ID, viewer.ID, CursorQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := followListIDs(page); !slices.Equal(got, []int64{fan3.ID, fan2.ID}) {
+		t.Fatalf("first page = %v, want fan3 then fan2", got)
+	}
+	if page[0].FollowsYou || !page[1].FollowsYou {
+		t.Fatal("follows_you should only be set for fan2")
+	}
+	if info.Next == nil || info.Prev != nil {
+		t.Fatalf("first page info = %+v, want only next", info)
+	}
+
+	page, info, err = followers.GetFollowers(ctx, star.ID, viewer.ID, CursorQuery{Limit: 2, Cursor: info.Next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := followListIDs(page); !slices.Equal(got, []int64{fan1.ID}) {
+		t.Fatalf("second page = %v, want fan1", got)
+	}
+	if info.Next != nil || info.Prev == nil {
+		t.Fatalf("last page info = %+v, want only prev", info)
+	}
+
+	following, _, err := followers.GetFollowing(ctx, fan2.ID, viewer.ID, CursorQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := followListIDs(following); !slices.Equal(got, []int64{viewer.ID, star.ID}) {
+		t.Fatalf("fan2 follows %v, want the viewer then star", got)
+	}
+}
+
+func followListIDs(entries []FollowListEntry) []int64 {
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.User.ID
+	}
+	return ids
+}
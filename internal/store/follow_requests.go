@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// FollowRequest is a pending request to follow a private user.
+type FollowRequest struct {
+	UserID      int64     `json:"user_id"`
+	RequesterID int64     `json:"requester_id"`
+	Requester   User      `json:"requester"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Request asks to follow the private user. It returns ErrConflict when the
+// request is pending already or the user is already followed, ErrBlocked
+// when either user blocked the other.
+func (s *FollowerStore) Request(ctx context.Context, requesterID, userID int64) error {
+	blocked, err := (&BlockStore{s.db}).IsBlocked(ctx, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return ErrBlocked
+	}
+
+	query := `
+		INSERT INTO follow_requests (user_id, requester_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// GetRequests lists the pending requests to follow the user, oldest first.
+func (s *FollowerStore) GetRequests(ctx context.Context, userID int64) ([]FollowRequest, error) {
+	query := `
+		SELECT fr.user_id, fr.requester_id, fr.created_at, u.username, u.display_name, u.avatar_url
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1 AND u.deleted_at IS NULL AND u.banned_at IS NULL
+		ORDER BY fr.created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		err := rows.Scan(
+			&fr.UserID,
+			&fr.RequesterID,
+			&fr.CreatedAt,
+			&fr.Requester.Username,
+			&fr.Requester.DisplayName,
+			&fr.Requester.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		fr.Requester.ID = fr.RequesterID
+		requests = append(requests, fr)
+	}
+
+	return requests, rows.Err()
+}
+
+// Approve turns the pending request into a follow.
+func (s *FollowerStore) Approve(ctx context.Context, userID, requesterID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteRequest(ctx, tx, userID, requesterID); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+		_, err := tx.ExecContext(ctx, query, userID, requesterID)
+		return err
+	})
+}
+
+// Reject drops the pending request.
+func (s *FollowerStore) Reject(ctx context.Context, userID, requesterID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.deleteRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+// IsFollowing tells whether the follower follows the user.
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+	return following, err
+}
+
+func (s *FollowerStore) deleteRequest(ctx context.Context, tx *sql.Tx, userID, requesterID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
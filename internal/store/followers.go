@@ -17,24 +17,42 @@ type FollowerStore struct {
 	db *sql.DB
 }
 
+// Follow returns ErrBlocked when either user blocked the other.
 func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
 	query := `
-		INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
+		INSERT INTO followers (user_id, follower_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (` + blockedQuery + `)
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	res, err := s.db.ExecContext(ctx, query, userID, followerID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
+
+	if rows == 0 {
+		return ErrBlocked
+	}
+
 	return nil
 }
 
+// Unfollow also cancels a pending follow request.
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
 	query := `
+		WITH requests AS (
+			DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2
+		)
 		DELETE FROM followers 
 		WHERE user_id = $1 AND follower_id = $2
 	`
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (s *UserStore) GetByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	query := `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// a soft deleted user logging in restores the account
+	return s.getByID(ctx, userID, true)
+}
+
+// CreateWithIdentity registers a user coming from an identity provider. The
+// provider verified the email so the user is active without an invitation.
+func (s *UserStore) CreateWithIdentity(ctx context.Context, user *User, identity *UserIdentity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		user.IsActive = true
+		if err := s.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return s.createIdentity(ctx, tx, identity)
+	})
+}
+
+// LinkIdentityByEmail attaches the identity to the user owning its (verified)
+// email. A pending invitation is no longer needed and the user gets activated.
+//
+// Note: whoever registered an account that was never activated didn't prove
+// they own the email, they could be waiting for its owner to log in with the
+// provider. The password and the tokens of such an account are dropped, its
+// owner can set a password with a reset.
+func (s *UserStore) LinkIdentityByEmail(ctx context.Context, identity *UserIdentity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT id, is_active FROM users WHERE email = $1 AND deleted_at IS NULL FOR UPDATE`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var isActive bool
+		if err := tx.QueryRowContext(ctx, query, identity.Email).Scan(&identity.UserID, &isActive); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := s.createIdentity(ctx, tx, identity); err != nil {
+			return err
+		}
+
+		if isActive {
+			return nil
+		}
+
+		query = `UPDATE users SET is_active = true, password = '' WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, identity.UserID); err != nil {
+			return err
+		}
+
+		if err := revokeAllTokens(ctx, tx, identity.UserID, time.Now().Truncate(time.Microsecond)); err != nil {
+			return err
+		}
+
+		return s.deleteUserInvitations(ctx, tx, identity.UserID)
+	})
+}
+
+func (s *UserStore) createIdentity(ctx context.Context, tx *sql.Tx, identity *UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(
+		&identity.ID,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+type OIDCState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	Expiry       time.Time
+}
+
+type OIDCStateStore struct {
+	db *sql.DB
+}
+
+// Note: only the hash of the state is stored, same as the user invitations
+func (s *OIDCStateStore) Create(ctx context.Context, state string, oidcState *OIDCState) error {
+	query := `
+		INSERT INTO oidc_states (state, provider, nonce, code_verifier, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		hashToken(state),
+		oidcState.Provider,
+		oidcState.Nonce,
+		oidcState.CodeVerifier,
+		oidcState.Expiry,
+	)
+	return err
+}
+
+// Consume returns the state and deletes it, a state can only be used once.
+func (s *OIDCStateStore) Consume(ctx context.Context, state string) (*OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE state = $1
+		RETURNING provider, nonce, code_verifier, expiry
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	oidcState := &OIDCState{}
+	err := s.db.QueryRowContext(ctx, query, hashToken(state)).Scan(
+		&oidcState.Provider,
+		&oidcState.Nonce,
+		&oidcState.CodeVerifier,
+		&oidcState.Expiry,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if oidcState.Expiry.Before(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	return oidcState, nil
+}
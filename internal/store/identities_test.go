@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLinkIdentityByEmailTakesOverAnUnactivatedAccount(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	sessions := &SessionStore{db}
+	ctx := context.Background()
+
+	// registered with a password by someone who never confirmed the email
+	squatter := &User{Username: "squatter", Email: "victim@example.com", Role: Role{Name: "user"}}
+	if err := squatter.Password.Set("the squatter password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.CreateAndInvite(ctx, squatter, invitationToken("invite"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	session := &Session{ID: uuid.NewString(), UserID: squatter.ID, UserAgent: "test", IP: "127.0.0.1"}
+	if err := sessions.Create(ctx, session, "squatter-refresh", &RefreshToken{Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := &UserIdentity{Provider: "stub", Subject: "victim-sub", Email: "victim@example.com"}
+	if err := users.LinkIdentityByEmail(ctx, identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != squatter.ID {
+		t.Fatalf("identity linked to user %d, want %d", identity.UserID, squatter.ID)
+	}
+
+	user, err := users.GetByEmail(ctx, "victim@example.com")
+	if err != nil {
+		t.Fatalf("the linked user isn't active: %v", err)
+	}
+	if err := user.Password.Compare("the squatter password"); err == nil {
+		t.Fatal("the password set before the link still works")
+	}
+
+	if err := sessions.Touch(ctx, squatter.ID, session.ID); err != ErrNotFound {
+		t.Fatalf("touch the session of the squatter: got %v, want ErrNotFound", err)
+	}
+
+	var invitations int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_invitations WHERE user_id = $1`, squatter.ID).Scan(&invitations); err != nil {
+		t.Fatal(err)
+	}
+	if invitations != 0 {
+		t.Fatalf("%d invitations left, want 0", invitations)
+	}
+}
+
+func TestLinkIdentityByEmailKeepsTheActiveAccount(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db, "owner")
+
+	identity := &UserIdentity{Provider: "stub", Subject: "owner-sub", Email: owner.Email}
+	if err := users.LinkIdentityByEmail(ctx, identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != owner.ID {
+		t.Fatalf("identity linked to user %d, want %d", identity.UserID, owner.ID)
+	}
+
+	user, err := users.GetByEmail(ctx, owner.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Password.Compare("correct horse battery staple"); err != nil {
+		t.Fatalf("the password of the active account was dropped: %v", err)
+	}
+}
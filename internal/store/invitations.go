@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ResendInvitation adds an invitation for the inactive user owning the email.
+// The pending invitations stay valid until DeleteOtherInvitations is called
+// once the new one is mailed. A new invitation is refused while the last one
+// is younger than cooldown.
+func (s *UserStore) ResendInvitation(ctx context.Context, email, token string, invitationExp, cooldown time.Duration) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = s.getInactiveByEmail(ctx, tx, email)
+		if err != nil {
+			return err
+		}
+
+		lastSent, err := s.getLastInvitationTime(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if lastSent != nil && time.Since(*lastSent) < cooldown {
+			return ErrThrottled
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationExp, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteOtherInvitations deletes the invitations of the user except the one
+// of token.
+func (s *UserStore) DeleteOtherInvitations(ctx context.Context, userID int64, token string) error {
+	query := `DELETE FROM user_invitations WHERE user_id = $1 AND token <> $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, token)
+	return err
+}
+
+// PurgeUnactivated deletes users that never activated their account and whose
+// invitations expired more than gracePeriod ago, then the invitations that
+// expired before that. It returns the number of deleted users.
+func (s *UserStore) PurgeUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	var purged int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM users u
+			WHERE u.is_active = false
+				AND u.created_at < $1
+				AND NOT EXISTS (
+					SELECT 1 FROM user_invitations ui WHERE ui.user_id = u.id AND ui.expiry > $1
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM posts p WHERE p.user_id = u.id
+				)
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		cutoff := time.Now().Add(-gracePeriod)
+
+		res, err := tx.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+
+		purged, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// the invitations of purged users expired before the cutoff too, so this
+		// also cleans them up. Those still in the grace period are kept, the
+		// users can still ask for them to be resent.
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_invitations WHERE expiry < $1`, cutoff)
+		return err
+	})
+
+	return purged, err
+}
+
+func (s *UserStore) getInactiveByEmail(ctx context.Context, tx *sql.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, created_at, is_active
+		FROM users
+		WHERE email = $1 AND is_active = false
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) getLastInvitationTime(ctx context.Context, tx *sql.Tx, userID int64) (*time.Time, error) {
+	query := `SELECT MAX(created_at) FROM user_invitations WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var lastSent sql.NullTime
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(&lastSent); err != nil {
+		return nil, err
+	}
+
+	if !lastSent.Valid {
+		return nil, nil
+	}
+
+	return &lastSent.Time, nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestInvitationLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	ctx := context.Background()
+
+	user := &User{Username: "invited", Email: "invited@example.com"}
+	if err := user.Password.Set("correct horse battery staple"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.CreateAndInvite(ctx, user, invitationToken("first"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.ResendInvitation(ctx, user.Email, invitationToken("second"), time.Hour, time.Hour); err != ErrThrottled {
+		t.Fatalf("resend within the cooldown: got %v, want ErrThrottled", err)
+	}
+
+	resent, err := users.ResendInvitation(ctx, user.Email, invitationToken("second"), time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resent.ID != user.ID {
+		t.Fatalf("resent to user %d, want %d", resent.ID, user.ID)
+	}
+
+	// the first link works until the second one is mailed
+	if n := countInvitations(t, db, user.ID); n != 2 {
+		t.Fatalf("%d invitations after the resend, want 2", n)
+	}
+	if err := users.DeleteOtherInvitations(ctx, user.ID, invitationToken("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.Activate(ctx, "first"); err != ErrNotFound {
+		t.Fatalf("activate with the replaced invitation: got %v, want ErrNotFound", err)
+	}
+
+	if _, err := db.Exec(`UPDATE users SET created_at = $1 WHERE id = $2`, time.Now().Add(-72*time.Hour), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	expire := func(ago time.Duration) {
+		t.Helper()
+		if _, err := db.Exec(`UPDATE user_invitations SET expiry = $1 WHERE user_id = $2`, time.Now().Add(-ago), user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	grace := 24 * time.Hour
+
+	// expired, but still in the grace period
+	expire(time.Hour)
+
+	if err := users.Activate(ctx, "second"); err != ErrNotFound {
+		t.Fatalf("activate with an expired invitation: got %v, want ErrNotFound", err)
+	}
+
+	purged, err := users.PurgeUnactivated(ctx, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatalf("purged %d users in the grace period, want 0", purged)
+	}
+	if n := countInvitations(t, db, user.ID); n != 1 {
+		t.Fatalf("%d invitations left in the grace period, want 1", n)
+	}
+
+	// the invitation can still be resent in the grace period
+	if _, err := users.ResendInvitation(ctx, user.Email, invitationToken("third"), time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	expire(grace + time.Hour)
+
+	purged, err = users.PurgeUnactivated(ctx, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d users after the grace period, want 1", purged)
+	}
+	if n := countInvitations(t, db, user.ID); n != 0 {
+		t.Fatalf("%d invitations left after the grace period, want 0", n)
+	}
+
+	if _, err := users.GetByID(ctx, user.ID); err != ErrNotFound {
+		t.Fatalf("get the purged user: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestPurgeUnactivatedKeepsActiveUsers(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+
+	user := createTestUser(t, db, "active")
+	if _, err := db.Exec(`UPDATE users SET created_at = $1 WHERE id = $2`, time.Now().Add(-72*time.Hour), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := users.PurgeUnactivated(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Fatalf("purged %d active users, want 0", purged)
+	}
+}
+
+func countInvitations(t *testing.T, db *sql.DB, userID int64) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM user_invitations WHERE user_id = $1`, userID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
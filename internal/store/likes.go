@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type LikeStore struct {
+	db *sql.DB
+}
+
+// Like is idempotent, liking a post twice keeps a single like.
+func (s *LikeStore) Like(ctx context.Context, userID, postID int64) error {
+	query := `INSERT INTO post_likes (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	return err
+}
+
+func (s *LikeStore) Unlike(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM post_likes WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
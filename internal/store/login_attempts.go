@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttempt struct {
+	Key         string
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	LastFailure time.Time
+}
+
+type LoginAttemptStore struct {
+	db *sql.DB
+}
+
+func (s *LoginAttemptStore) Get(ctx context.Context, key string) (*LoginAttempt, error) {
+	query := `
+		SELECT key, failures, lockouts, locked_until, last_failure_at
+		FROM login_attempts
+		WHERE key = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	attempt, err := scanLoginAttempt(s.db.QueryRowContext(ctx, query, key))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return attempt, nil
+}
+
+// RecordFailure increments the failure counter for the key. Failures older than
+// the window are forgotten so a typo now and then never adds up to a lockout.
+func (s *LoginAttemptStore) RecordFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING key, failures, lockouts, locked_until, last_failure_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return scanLoginAttempt(s.db.QueryRowContext(ctx, query, key, window.Seconds()))
+}
+
+func (s *LoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $2, lockouts = lockouts + 1, failures = 0
+		WHERE key = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+func (s *LoginAttemptStore) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, key)
+	return err
+}
+
+// DeleteStale removes the keys that aren't locked and didn't fail since
+// before, their next lockout starts over from the base duration. It returns the
+// number of deleted keys.
+func (s *LoginAttemptStore) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE (locked_until IS NULL OR locked_until < NOW()) AND last_failure_at < $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func scanLoginAttempt(row *sql.Row) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{}
+	var lockedUntil sql.NullTime
+
+	err := row.Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.Lockouts,
+		&lockedUntil,
+		&attempt.LastFailure,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		attempt.LockedUntil = lockedUntil.Time
+	}
+
+	return attempt, nil
+}
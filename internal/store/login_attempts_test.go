@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeleteStaleLoginAttempts(t *testing.T) {
+	db := newTestDB(t)
+	attempts := &LoginAttemptStore{db}
+	ctx := context.Background()
+
+	for _, key := range []string{"ip:quiet", "ip:recent", "ip:locked"} {
+		if _, err := attempts.RecordFailure(ctx, key, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-48 * time.Hour)
+	if _, err := db.Exec(`UPDATE login_attempts SET last_failure_at = $1 WHERE key IN ('ip:quiet', 'ip:locked')`, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := attempts.Lock(ctx, "ip:locked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := attempts.DeleteStale(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d keys, want 1", deleted)
+	}
+
+	if _, err := attempts.Get(ctx, "ip:quiet"); err != ErrNotFound {
+		t.Fatalf("quiet key: got %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"ip:recent", "ip:locked"} {
+		if _, err := attempts.Get(ctx, key); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+}
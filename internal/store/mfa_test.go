@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMFAStepsAndRecoveryCodesAreSingleUse(t *testing.T) {
+	db := newTestDB(t)
+	mfa := &MFAStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "second-factor")
+
+	if err := mfa.CreatePending(ctx, user.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfa.Enable(ctx, user.ID, 100, []string{"first", "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		step int64
+		want error
+	}{
+		{step: 100, want: ErrConflict},
+		{step: 99, want: ErrConflict},
+		{step: 101, want: nil},
+		{step: 101, want: ErrConflict},
+		{step: 103, want: nil},
+		{step: 102, want: ErrConflict},
+	}
+	for _, tt := range steps {
+		if err := mfa.UseStep(ctx, user.ID, tt.step); err != tt.want {
+			t.Errorf("use step %d: got %v, want %v", tt.step, err, tt.want)
+		}
+	}
+
+	codes := []struct {
+		code string
+		want error
+	}{
+		{code: "first", want: nil},
+		{code: "first", want: ErrNotFound},
+		{code: "unknown", want: ErrNotFound},
+		{code: "second", want: nil},
+	}
+	for _, tt := range codes {
+		if err := mfa.UseRecoveryCode(ctx, user.ID, tt.code); err != tt.want {
+			t.Errorf("use recovery code %q: got %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
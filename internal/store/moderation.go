@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	ModerationSuspend = "suspend"
+	ModerationBan     = "ban"
+	ModerationLift    = "lift"
+	ModerationAppeal  = "appeal"
+)
+
+// ModerationAction is an entry of the moderation audit log.
+type ModerationAction struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	ModeratorID *int64     `json:"moderator_id"`
+	Action      string     `json:"action"`
+	Reason      string     `json:"reason"`
+	AppealNote  string     `json:"appeal_note"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Sanctioned tells whether the user is banned or currently suspended.
+func (u *User) Sanctioned() bool {
+	return u.BannedAt != nil || (u.SuspendedUntil != nil && u.SuspendedUntil.After(time.Now()))
+}
+
+type ModerationStore struct {
+	db *sql.DB
+}
+
+// Suspend keeps the user out until action.ExpiresAt.
+func (s *ModerationStore) Suspend(ctx context.Context, action *ModerationAction) error {
+	action.Action = ModerationSuspend
+	query := `UPDATE users SET suspended_until = $1, moderation_reason = $2 WHERE id = $3`
+
+	return s.apply(ctx, action, query, action.ExpiresAt, action.Reason, action.UserID)
+}
+
+// Ban keeps the user out until the ban is lifted.
+func (s *ModerationStore) Ban(ctx context.Context, action *ModerationAction) error {
+	action.Action = ModerationBan
+	action.ExpiresAt = nil
+	query := `UPDATE users SET banned_at = NOW(), moderation_reason = $1 WHERE id = $2`
+
+	return s.apply(ctx, action, query, action.Reason, action.UserID)
+}
+
+// Lift ends the suspension or ban of the user.
+func (s *ModerationStore) Lift(ctx context.Context, action *ModerationAction) error {
+	action.Action = ModerationLift
+	action.ExpiresAt = nil
+	query := `UPDATE users SET suspended_until = NULL, banned_at = NULL, moderation_reason = '' WHERE id = $1`
+
+	return s.apply(ctx, action, query, action.UserID)
+}
+
+// Appeal records the appeal of a sanctioned user for the moderators. A
+// sanction is appealed once, ErrConflict is returned when the last suspension
+// or ban of the user was appealed already.
+func (s *ModerationStore) Appeal(ctx context.Context, action *ModerationAction) error {
+	action.Action = ModerationAppeal
+	action.ModeratorID = nil
+	action.ExpiresAt = nil
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// serialize the appeals of the user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, action.UserID); err != nil {
+			return err
+		}
+
+		query := `
+			SELECT EXISTS (
+				SELECT 1 FROM moderation_actions
+				WHERE user_id = $1 AND action = $2 AND id > COALESCE((
+					SELECT MAX(id) FROM moderation_actions
+					WHERE user_id = $1 AND action IN ($3, $4)
+				), 0)
+			)
+		`
+
+		var appealed bool
+		err := tx.QueryRowContext(ctx, query, action.UserID, ModerationAppeal, ModerationSuspend, ModerationBan).Scan(&appealed)
+		if err != nil {
+			return err
+		}
+		if appealed {
+			return ErrConflict
+		}
+
+		return s.createAction(ctx, tx, action)
+	})
+}
+
+func (s *ModerationStore) GetByUserID(ctx context.Context, userID int64) ([]ModerationAction, error) {
+	query := `
+		SELECT id, user_id, moderator_id, action, reason, appeal_note, expires_at, created_at
+		FROM moderation_actions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		var (
+			a           ModerationAction
+			moderatorID sql.NullInt64
+			expiresAt   sql.NullTime
+		)
+		err := rows.Scan(
+			&a.ID,
+			&a.UserID,
+			&moderatorID,
+			&a.Action,
+			&a.Reason,
+			&a.AppealNote,
+			&expiresAt,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if moderatorID.Valid {
+			a.ModeratorID = &moderatorID.Int64
+		}
+		if expiresAt.Valid {
+			a.ExpiresAt = &expiresAt.Time
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// apply runs the update of the user and records the action in the same transaction.
+func (s *ModerationStore) apply(ctx context.Context, action *ModerationAction, query string, args ...any) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return s.createAction(ctx, tx, action)
+	})
+}
+
+func (s *ModerationStore) createAction(ctx context.Context, tx *sql.Tx, action *ModerationAction) error {
+	query := `
+		INSERT INTO moderation_actions (user_id, moderator_id, action, reason, appeal_note, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		action.UserID,
+		action.ModeratorID,
+		action.Action,
+		action.Reason,
+		action.AppealNote,
+		action.ExpiresAt,
+	).Scan(
+		&action.ID,
+		&action.CreatedAt,
+	)
+}
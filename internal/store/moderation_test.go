@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAppealOncePerSanction(t *testing.T) {
+	db := newTestDB(t)
+	moderation := &ModerationStore{db}
+	ctx := context.Background()
+
+	moderator := createTestUser(t, db, "moderator")
+	user := createTestUser(t, db, "appealing")
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := moderation.Suspend(ctx, &ModerationAction{UserID: user.ID, ModeratorID: &moderator.ID, Reason: "spam", ExpiresAt: &expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := moderation.Appeal(ctx, &ModerationAction{UserID: user.ID, AppealNote: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := moderation.Appeal(ctx, &ModerationAction{UserID: user.ID, AppealNote: "second"}); err != ErrConflict {
+		t.Fatalf("second appeal of the suspension: got %v, want ErrConflict", err)
+	}
+
+	if err := moderation.Ban(ctx, &ModerationAction{UserID: user.ID, ModeratorID: &moderator.ID, Reason: "spam again"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := moderation.Appeal(ctx, &ModerationAction{UserID: user.ID, AppealNote: "about the ban"}); err != nil {
+		t.Fatalf("appeal of the ban: %v", err)
+	}
+
+	actions, err := moderation.GetByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 4 {
+		t.Fatalf("%d actions in the log, want 4", len(actions))
+	}
+}
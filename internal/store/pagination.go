@@ -1,22 +1,41 @@
 package store
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var errInvalidTimeRange = errors.New("since must be before until")
+
 type PaginatedFeedQuery struct {
-	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
-	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
-	Tags   []string `json:"tags" validate:"max=5"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	// Mode is chronological, ordered by Sort, or ranked
+	Mode   string   `json:"mode" validate:"oneof=chronological ranked"`
+	Tags   []string `json:"tags" validate:"max=5,dive,required,max=50"`
 	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"string"`
+	// Since and Until bound the creation time of the posts, Since included
+	// and Until excluded. Nil leaves the side open.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+	// Cursor and Backward page with keyset pagination, Offset is deprecated
+	Cursor   *Cursor `json:"-"`
+	Backward bool    `json:"-"`
+}
+
+func (fq PaginatedFeedQuery) cursorQuery() CursorQuery {
+	return CursorQuery{Limit: fq.Limit, Cursor: fq.Cursor, Backward: fq.Backward}
 }
 
+// Parse reads the query string into fq. Malformed values are rejected, they
+// are not silently replaced by the defaults.
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
 	qs := r.URL.Query()
 
@@ -24,7 +43,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if limit != "" {
 		l, err := strconv.Atoi(limit)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid limit: %q", limit)
 		}
 		fq.Limit = l
 	}
@@ -32,7 +51,7 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if offset != "" {
 		l, err := strconv.Atoi(offset)
 		if err != nil {
-			return fq, nil
+			return fq, fmt.Errorf("invalid offset: %q", offset)
 		}
 		fq.Offset = l
 	}
@@ -42,6 +61,11 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Sort = sort
 	}
 
+	mode := qs.Get("mode")
+	if mode != "" {
+		fq.Mode = mode
+	}
+
 	tags := qs.Get("tags")
 	if tags != "" {
 		fq.Tags = strings.Split(tags, ",")
@@ -54,20 +78,115 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 
 	since := qs.Get("since")
 	if since != "" {
-		fq.Since = parseTime(since)
+		t, err := parseTime(since)
+		if err != nil {
+			return fq, fmt.Errorf("invalid since: %q", since)
+		}
+		fq.Since = &t
 	}
 
 	until := qs.Get("until")
 	if until != "" {
-		fq.Until = parseTime(until)
+		t, err := parseTime(until)
+		if err != nil {
+			return fq, fmt.Errorf("invalid until: %q", until)
+		}
+		fq.Until = &t
+	}
+
+	if fq.Since != nil && fq.Until != nil && !fq.Since.Before(*fq.Until) {
+		return fq, errInvalidTimeRange
 	}
+
 	return fq, nil
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
-	if err != nil {
-		return ""
+// parseTime accepts RFC 3339 times and, in UTC, "2006-01-02 15:04:05" and
+// "2006-01-02".
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
 	}
-	return t.Format(time.DateTime)
+	return time.Time{}, errors.New("unsupported time format")
+}
+
+// Cursor is the position of a row in a list ordered by creation time, then id.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// CursorQuery asks for the Limit rows following Cursor in the list order, or
+// preceding it when Backward. A nil Cursor starts at the top of the list.
+type CursorQuery struct {
+	Limit    int     `validate:"gte=1,lte=100"`
+	Cursor   *Cursor `validate:"-"`
+	Backward bool
+}
+
+// PageInfo holds the positions to page from, they are nil at the ends of the list.
+type PageInfo struct {
+	// Next is the last row of the page, the next page follows it
+	Next *Cursor
+	// Prev is the first row of the page, the previous page precedes it
+	Prev *Cursor
+}
+
+// keyset returns the condition and ORDER BY clause paging through rows
+// ordered by timeColumn then idColumn, descending when desc. The condition
+// uses the placeholders $n and $n+1 bound to args.
+func (q CursorQuery) keyset(desc bool, timeColumn, idColumn string, n int) (string, string, []any) {
+	op, dir := ">", "ASC"
+	if desc != q.Backward {
+		op, dir = "<", "DESC"
+	}
+
+	cond := fmt.Sprintf("($%d::timestamptz IS NULL OR (%s, %s) %s ($%d, $%d))", n, timeColumn, idColumn, op, n, n+1)
+	order := fmt.Sprintf("%s %s, %s %s", timeColumn, dir, idColumn, dir)
+
+	var (
+		after   sql.NullTime
+		afterID int64
+	)
+	if q.Cursor != nil {
+		after = sql.NullTime{Time: q.Cursor.CreatedAt, Valid: true}
+		afterID = q.Cursor.ID
+	}
+
+	return cond, order, []any{after, afterID}
+}
+
+// paginate trims the rows fetched with LIMIT q.Limit + 1 to the page, puts
+// them back in list order and finds where the neighbouring pages start.
+func paginate[T any](rows []T, positions []Cursor, q CursorQuery) ([]T, PageInfo) {
+	more := len(rows) > q.Limit
+	if more {
+		rows, positions = rows[:q.Limit], positions[:q.Limit]
+	}
+
+	if q.Backward {
+		slices.Reverse(rows)
+		slices.Reverse(positions)
+	}
+
+	var info PageInfo
+	if len(rows) == 0 {
+		return rows, info
+	}
+
+	hasNext, hasPrev := more, q.Cursor != nil
+	if q.Backward {
+		hasNext, hasPrev = q.Cursor != nil, more
+	}
+
+	if hasNext {
+		info.Next = &positions[len(positions)-1]
+	}
+	if hasPrev {
+		info.Prev = &positions[0]
+	}
+
+	return rows, info
 }
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaginatedFeedQueryParseTimeRange(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantSince *time.Time
+		wantUntil *time.Time
+		wantErr   bool
+	}{
+		{name: "open range", query: ""},
+		{name: "date", query: "since=2024-05-01", wantSince: &day},
+		{name: "date time", query: "until=2024-05-01+00:00:00", wantUntil: &day},
+		{name: "rfc 3339", query: "since=2024-05-01T02:00:00%2B02:00", wantSince: &day},
+		{
+			name:      "both",
+			query:     "since=2024-05-01&until=2024-05-02",
+			wantSince: &day,
+			wantUntil: ptr(day.AddDate(0, 0, 1)),
+		},
+		{name: "malformed since", query: "since=yesterday", wantErr: true},
+		{name: "malformed until", query: "until=2024-13-01", wantErr: true},
+		{name: "empty range", query: "since=2024-05-01&until=2024-05-01", wantErr: true},
+		{name: "reversed range", query: "since=2024-05-02&until=2024-05-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/users/feed?"+tt.query, nil)
+
+			fq, err := PaginatedFeedQuery{}.Parse(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !sameTime(fq.Since, tt.wantSince) {
+				t.Errorf("since = %v, want %v", fq.Since, tt.wantSince)
+			}
+			if !sameTime(fq.Until, tt.wantUntil) {
+				t.Errorf("until = %v, want %v", fq.Until, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestPaginatedFeedQueryParse(t *testing.T) {
+	defaults := PaginatedFeedQuery{Limit: 20, Offset: 0, Sort: "desc", Mode: "chronological"}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    PaginatedFeedQuery
+		wantErr string
+	}{
+		{name: "defaults", query: "", want: defaults},
+		{
+			name:  "every value",
+			query: "limit=5&offset=10&sort=asc&mode=ranked&tags=go,db&search=gopher",
+			want:  PaginatedFeedQuery{Limit: 5, Offset: 10, Sort: "asc", Mode: "ranked", Tags: []string{"go", "db"}, Search: "gopher"},
+		},
+		{name: "limit not a number", query: "limit=ten", wantErr: `invalid limit: "ten"`},
+		{name: "fractional limit", query: "limit=2.5", wantErr: `invalid limit: "2.5"`},
+		{name: "limit overflow", query: "limit=99999999999999999999", wantErr: "invalid limit"},
+		{name: "offset not a number", query: "offset=x", wantErr: `invalid offset: "x"`},
+		{name: "since not a time", query: "since=yesterday", wantErr: `invalid since: "yesterday"`},
+		{name: "until out of range", query: "until=2024-02-30", wantErr: `invalid until: "2024-02-30"`},
+		{name: "bad value after good ones", query: "limit=5&since=2024-05-01&until=soon", wantErr: `invalid until: "soon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/users/feed?"+tt.query, nil)
+
+			fq, err := defaults.Parse(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fq.Limit != tt.want.Limit || fq.Offset != tt.want.Offset || fq.Sort != tt.want.Sort ||
+				fq.Mode != tt.want.Mode || fq.Search != tt.want.Search || !slices.Equal(fq.Tags, tt.want.Tags) {
+				t.Fatalf("parsed %+v, want %+v", fq, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCreatePasswordResetCooldown(t *testing.T) {
+	db := newTestDB(t)
+	users := &UserStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "forgetful")
+
+	if err := users.CreatePasswordReset(ctx, user.ID, invitationToken("first"), time.Hour, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.CreatePasswordReset(ctx, user.ID, invitationToken("second"), time.Hour, time.Hour); err != ErrThrottled {
+		t.Fatalf("reset within the cooldown: got %v, want ErrThrottled", err)
+	}
+
+	if err := users.CreatePasswordReset(ctx, user.ID, invitationToken("second"), time.Hour, 0); err != nil {
+		t.Fatalf("reset after the cooldown: %v", err)
+	}
+
+	if _, err := users.GetByPasswordReset(ctx, invitationToken("first")); err != nil {
+		t.Fatalf("the first reset went: %v", err)
+	}
+}
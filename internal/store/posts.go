@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -38,53 +39,192 @@ type PostStore struct {
 // Note: We can use ORM (more friendly) like GORM to avoid writing sql
 // sqlx, sqlboiler are other libraries to make life easier
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, error) {
+// GetUserFeed returns a page of the posts of the user and of the users they
+// follow, which private users only allow once they approved them.
+func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetaData, PageInfo, error) {
+	cq := fq.cursorQuery()
+	cond, order, keysetArgs := cq.keyset(fq.Sort == "desc", "p.created_at", "p.id", 8)
+
 	query := `
 		SELECT 
 			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
 			u.username,
-			COUNT(c.id) AS comments_count
+			COUNT(c.id) FILTER (WHERE cu.deleted_at IS NULL AND cu.banned_at IS NULL) AS comments_count
 		FROM posts p
 		LEFT JOIN comments c ON c.post_id = p.id
+		LEFT JOIN users cu ON c.user_id = cu.id
 		LEFT JOIN users u ON p.user_id = u.id
-		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
 		WHERE 
-			(f.user_id = $1) AND
+			(p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+			)) AND
+			(u.deleted_at IS NULL AND u.banned_at IS NULL) AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+			) AND
+			NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id) AND
 			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND 
-			(p.tags @> $5 OR $5 = '{}')
+			(p.tags @> $5 OR $5 = '{}') AND
+			($6::timestamptz IS NULL OR p.created_at >= $6) AND
+			($7::timestamptz IS NULL OR p.created_at < $7) AND
+			` + cond + `
 		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + fq.Sort + `
+		ORDER BY ` + order + `
 		LIMIT $2 OFFSET $3
 	`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	args := append([]any{userID, fq.Limit + 1, fq.Offset, fq.Search, pq.Array(fq.Tags), fq.Since, fq.Until}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	feed, positions, err := scanFeed(rows)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	feed, info := paginate(feed, positions, cq)
+	if fq.Offset > 0 && len(feed) > 0 {
+		info.Prev = &positions[0]
+	}
+
+	return feed, info, nil
+}
+
+// GetTimelinePosts loads the posts of the entries of a materialised timeline
+// with their positions, newest first. Posts the viewer may no longer see are
+// left out, the page is cut from what remains.
+func (s *PostStore) GetTimelinePosts(ctx context.Context, viewerID int64, entries []Cursor) ([]PostWithMetaData, []Cursor, error) {
+	if len(entries) == 0 {
+		return []PostWithMetaData{}, nil, nil
+	}
+
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+
+	query := `
+		SELECT 
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			u.username,
+			COUNT(c.id) FILTER (WHERE cu.deleted_at IS NULL AND cu.banned_at IS NULL) AS comments_count
+		FROM posts p
+		LEFT JOIN comments c ON c.post_id = p.id
+		LEFT JOIN users cu ON c.user_id = cu.id
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE 
+			p.id = ANY($2) AND
+			(p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+			)) AND
+			(u.deleted_at IS NULL AND u.banned_at IS NULL) AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+			) AND
+			NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id)
+		GROUP BY p.id, u.username
+		ORDER BY p.created_at DESC, p.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	return scanFeed(rows)
+}
+
+// GetFanOutOnReadPositions returns up to limit positions following after of
+// the posts of the viewer and the users they follow having more than
+// minFollowers followers. Those posts are not pushed to the timelines. The
+// authors the viewer may not see are left out like in GetTimelinePosts.
+func (s *PostStore) GetFanOutOnReadPositions(ctx context.Context, viewerID int64, minFollowers int, after *Cursor, limit int) ([]Cursor, error) {
+	cond, order, keysetArgs := CursorQuery{Cursor: after}.keyset(true, "p.created_at", "p.id", 4)
+
+	query := `
+		SELECT p.id, p.created_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE 
+			u.followers_count > $2 AND
+			(p.user_id = $1 OR EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+			)) AND
+			(u.deleted_at IS NULL AND u.banned_at IS NULL) AND
+			NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+			) AND
+			NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id) AND
+			` + cond + `
+		ORDER BY ` + order + `
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{viewerID, minFollowers, limit}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostWithMetaData
+	var positions []Cursor
+	for rows.Next() {
+		var c Cursor
+		if err := rows.Scan(&c.ID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		positions = append(positions, c)
+	}
+
+	return positions, rows.Err()
+}
+
+func scanFeed(rows *sql.Rows) ([]PostWithMetaData, []Cursor, error) {
+	feed := []PostWithMetaData{}
+	var positions []Cursor
 	for rows.Next() {
-		var p PostWithMetaData
+		var (
+			p         PostWithMetaData
+			createdAt time.Time
+		)
 		err := rows.Scan(
 			&p.ID,
 			&p.UserID,
 			&p.Title,
 			&p.Content,
-			&p.CreatedAt,
+			&createdAt,
 			&p.Version,
 			pq.Array(&p.Tags),
 			&p.User.Username,
 			&p.CommentCount,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		// same format as scanning the timestamp into the string
+		p.CreatedAt = createdAt.Format(time.RFC3339Nano)
 		feed = append(feed, p)
+		positions = append(positions, Cursor{CreatedAt: createdAt, ID: p.ID})
 	}
-	return feed, nil
+
+	return feed, positions, rows.Err()
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
@@ -119,9 +259,10 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	// instead of this SELECT * FROM posts mention everything explicitly is better instead of implicit.
 	query := `
-	SELECT id, user_id, title, content, created_at, updated_at, tags, version
-	FROM posts
-	WHERE id = $1
+	SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.tags, p.version
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = $1 AND u.deleted_at IS NULL AND u.banned_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetUserFeed(t *testing.T) {
+	db := newTestDB(t)
+	posts := &PostStore{db}
+	followers := &FollowerStore{db}
+	blocks := &BlockStore{db}
+	ctx := context.Background()
+
+	viewer := createTestUser(t, db, "viewer")
+	followed := createTestUser(t, db, "followed")
+	stranger := createTestUser(t, db, "stranger")
+	muted := createTestUser(t, db, "muted")
+	blocking := createTestUser(t, db, "blocking")
+
+	for _, u := range []*User{followed, muted, blocking} {
+		if err := followers.Follow(ctx, viewer.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	own := createTestPost(t, db, viewer.ID, day)
+	old := createTestPost(t, db, followed.ID, day.Add(-48*time.Hour))
+	recent := createTestPost(t, db, followed.ID, day.Add(12*time.Hour))
+	createTestPost(t, db, stranger.ID, day)
+	// the stranger following the viewer doesn't bring their posts in
+	if err := followers.Follow(ctx, stranger.ID, viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(`UPDATE posts SET tags = '{go,db}' WHERE id = $1`, own); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE posts SET tags = '{go}', title = 'Gopher news' WHERE id = $1`, recent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE posts SET content = 'all about gophers' WHERE id = $1`, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// followed before the mute and the block, they match every query
+	for _, u := range []*User{muted, blocking} {
+		id := createTestPost(t, db, u.ID, day)
+		if _, err := db.Exec(`UPDATE posts SET tags = '{go,db}', title = 'Gopher' WHERE id = $1`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := blocks.Mute(ctx, viewer.ID, muted.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Block would remove the follow, the row alone tells the feed to skip the posts
+	if _, err := db.Exec(`INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1, $2)`, blocking.ID, viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		since  *time.Time
+		until  *time.Time
+		tags   []string
+		search string
+		sort   string
+		want   []int64
+	}{
+		{name: "open range", want: []int64{recent, own, old}},
+		{name: "since is included", since: ptr(day), want: []int64{recent, own}},
+		{name: "until is excluded", until: ptr(day), want: []int64{old}},
+		{name: "both", since: ptr(day.Add(-time.Hour)), until: ptr(day.Add(time.Hour)), want: []int64{own}},
+		{name: "nothing in range", since: ptr(day.Add(24 * time.Hour)), want: []int64{}},
+		{name: "oldest first", sort: "asc", want: []int64{old, own, recent}},
+		{name: "one tag", tags: []string{"go"}, want: []int64{recent, own}},
+		{name: "every tag", tags: []string{"go", "db"}, want: []int64{own}},
+		{name: "unknown tag", tags: []string{"rust"}, want: []int64{}},
+		{name: "search the title and content", search: "gopher", want: []int64{recent, old}},
+		{name: "search and tags", search: "gopher", tags: []string{"go"}, want: []int64{recent}},
+		{name: "search and range", search: "gopher", until: ptr(day), sort: "asc", want: []int64{old}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fq := PaginatedFeedQuery{
+				Limit:  20,
+				Sort:   "desc",
+				Mode:   "chronological",
+				Tags:   tt.tags,
+				Search: tt.search,
+				Since:  tt.since,
+				Until:  tt.until,
+			}
+			if tt.sort != "" {
+				fq.Sort = tt.sort
+			}
+
+			feed, _, err := posts.GetUserFeed(ctx, viewer.ID, fq)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]int64, len(feed))
+			for i, p := range feed {
+				got[i] = p.ID
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("feed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// createTestPost creates a post of the user created at createdAt and returns
+// its id.
+func createTestPost(t *testing.T, db *sql.DB, userID int64, createdAt time.Time) int64 {
+	t.Helper()
+
+	post := &Post{UserID: userID, Title: "title", Content: "content", Tags: []string{}}
+	if err := (&PostStore{db}).Create(context.Background(), post); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(`UPDATE posts SET created_at = $1 WHERE id = $2`, createdAt, post.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	return post.ID
+}
+
+func TestTimelineLeavesHiddenAuthorsOut(t *testing.T) {
+	db := newTestDB(t)
+	posts := &PostStore{db}
+	followers := &FollowerStore{db}
+	blocks := &BlockStore{db}
+	ctx := context.Background()
+
+	viewer := createTestUser(t, db, "viewer")
+	followed := createTestUser(t, db, "followed")
+	muted := createTestUser(t, db, "muted")
+	blocking := createTestUser(t, db, "blocking")
+	banned := createTestUser(t, db, "banned")
+
+	for _, u := range []*User{followed, muted, blocking, banned} {
+		if err := followers.Follow(ctx, viewer.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := blocks.Mute(ctx, viewer.ID, muted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1, $2)`, blocking.ID, viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE users SET banned_at = NOW() WHERE id = $1`, banned.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	visible := createTestPost(t, db, followed.ID, now.Add(-time.Minute))
+	for i, u := range []*User{muted, blocking, banned} {
+		createTestPost(t, db, u.ID, now.Add(-time.Duration(i+2)*time.Minute))
+	}
+
+	// every author is read on the fly with no minimum of followers
+	positions, err := posts.GetFanOutOnReadPositions(ctx, viewer.ID, -1, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].ID != visible {
+		t.Fatalf("fan out on read positions = %v, want post %d only", positions, visible)
+	}
+
+	rows, err := db.Query(`SELECT id, created_at FROM posts ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []Cursor
+	for rows.Next() {
+		var c Cursor
+		if err := rows.Scan(&c.ID, &c.CreatedAt); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, c)
+	}
+	rows.Close()
+
+	feed, feedPositions, err := posts.GetTimelinePosts(ctx, viewer.ID, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed) != 1 || feed[0].ID != visible || feedPositions[0].ID != visible {
+		t.Fatalf("timeline posts = %v, want post %d only", feed, visible)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// publicAuthor matches the posts anyone may see: those of public authors who
+// are neither deleted nor banned.
+const publicAuthor = `NOT u.is_private AND u.deleted_at IS NULL AND u.banned_at IS NULL`
+
+// TrendingTag is a tag used by the posts of a time window.
+type TrendingTag struct {
+	Tag          string `json:"tag"`
+	PostsCount   int    `json:"posts_count"`
+	AuthorsCount int    `json:"authors_count"`
+}
+
+// GetPublic returns a page of the posts of public authors, newest first.
+// A non empty tag only keeps the posts having it.
+func (s *PostStore) GetPublic(ctx context.Context, tag string, q CursorQuery) ([]PostWithMetaData, PageInfo, error) {
+	args := []any{q.Limit + 1}
+	tagCond := "TRUE"
+	if tag != "" {
+		// the containment operator uses idx_posts_tags
+		args = append(args, pq.Array([]string{tag}))
+		tagCond = "p.tags @> $2"
+	}
+
+	cond, order, keysetArgs := q.keyset(true, "p.created_at", "p.id", len(args)+1)
+	args = append(args, keysetArgs...)
+
+	query := `
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			u.username,
+			COUNT(c.id) FILTER (WHERE cu.deleted_at IS NULL AND cu.banned_at IS NULL) AS comments_count
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		LEFT JOIN comments c ON c.post_id = p.id
+		LEFT JOIN users cu ON c.user_id = cu.id
+		WHERE
+			` + publicAuthor + ` AND
+			` + tagCond + ` AND
+			` + cond + `
+		GROUP BY p.id, u.username
+		ORDER BY ` + order + `
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	posts, positions, err := scanFeed(rows)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	posts, info := paginate(posts, positions, q)
+	return posts, info, nil
+}
+
+// GetTrendingTags ranks the tags of the public posts created between since
+// and until by the number of authors using them, then of posts. It skips
+// the first offset tags.
+func (s *PostStore) GetTrendingTags(ctx context.Context, since, until time.Time, offset, limit int) ([]TrendingTag, error) {
+	query := `
+		SELECT tag, COUNT(*) AS posts_count, COUNT(DISTINCT p.user_id) AS authors_count
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		CROSS JOIN unnest(p.tags) AS tag
+		WHERE
+			` + publicAuthor + ` AND
+			p.created_at >= $1 AND p.created_at <= $2
+		GROUP BY tag
+		ORDER BY authors_count DESC, posts_count DESC, tag
+		OFFSET $3 LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, until, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.PostsCount, &t.AuthorsCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RankingCandidate is a post the ranked feed may show, with the signals it
+// is scored on.
+type RankingCandidate struct {
+	PostWithMetaData
+	LikeCount int `json:"likes_count"`
+	// Interactions counts the comments and likes of the viewer on the other
+	// posts of the author
+	Interactions  int       `json:"-"`
+	CreatedAtTime time.Time `json:"-"`
+}
+
+// rankingVisibleQuery leaves out the posts the viewer $1 may not see: of
+// deleted or banned authors u, and of the authors in a block with or muted by
+// the viewer.
+const rankingVisibleQuery = `
+	u.deleted_at IS NULL AND u.banned_at IS NULL AND
+	NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+	) AND
+	NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id)
+`
+
+// GetRankingCandidates returns the candidates of the ranked feed created
+// between since and until: the latest posts of the viewer and the users they
+// follow, and the most commented and liked posts of public authors, limit of
+// each. They are ordered newest first. Only the comments and likes up to
+// until are counted, the same until returns the same candidates.
+func (s *PostStore) GetRankingCandidates(ctx context.Context, viewerID int64, since, until time.Time, limit int) ([]RankingCandidate, error) {
+	query := `
+		WITH candidates AS (
+			(
+				SELECT p.id
+				FROM posts p
+				JOIN users u ON u.id = p.user_id
+				WHERE
+					p.created_at >= $2 AND p.created_at <= $3 AND
+					(p.user_id = $1 OR EXISTS (
+						SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1
+					)) AND
+					` + rankingVisibleQuery + `
+				ORDER BY p.created_at DESC, p.id DESC
+				LIMIT $4
+			)
+			UNION
+			(
+				-- the engagement on posts of the window comes after since
+				SELECT p.id
+				FROM posts p
+				JOIN users u ON u.id = p.user_id
+				LEFT JOIN (
+					SELECT e.post_id, COUNT(*) AS total
+					FROM (
+						SELECT post_id FROM comments WHERE created_at >= $2 AND created_at <= $3
+						UNION ALL
+						SELECT post_id FROM post_likes WHERE created_at >= $2 AND created_at <= $3
+					) e
+					GROUP BY e.post_id
+				) engagement ON engagement.post_id = p.id
+				WHERE
+					p.created_at >= $2 AND p.created_at <= $3 AND NOT u.is_private AND
+					` + rankingVisibleQuery + `
+				ORDER BY COALESCE(engagement.total, 0) DESC, p.id DESC
+				LIMIT $4
+			)
+		)
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+			u.username,
+			(
+				SELECT COUNT(*) FROM comments c
+				JOIN users cu ON cu.id = c.user_id
+				WHERE c.post_id = p.id AND c.created_at <= $3 AND cu.deleted_at IS NULL AND cu.banned_at IS NULL
+			) AS comments_count,
+			(SELECT COUNT(*) FROM post_likes l WHERE l.post_id = p.id AND l.created_at <= $3) AS likes_count,
+			(
+				SELECT COUNT(*) FROM comments c
+				JOIN posts ap ON ap.id = c.post_id
+				WHERE c.user_id = $1 AND ap.user_id = p.user_id AND ap.user_id <> $1 AND ap.id <> p.id AND c.created_at <= $3
+			) + (
+				SELECT COUNT(*) FROM post_likes l
+				JOIN posts ap ON ap.id = l.post_id
+				WHERE l.user_id = $1 AND ap.user_id = p.user_id AND ap.user_id <> $1 AND ap.id <> p.id AND l.created_at <= $3
+			) AS interactions
+		FROM candidates
+		JOIN posts p ON p.id = candidates.id
+		JOIN users u ON u.id = p.user_id
+		ORDER BY p.created_at DESC, p.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []RankingCandidate
+	for rows.Next() {
+		var c RankingCandidate
+		err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.Title,
+			&c.Content,
+			&c.CreatedAtTime,
+			&c.Version,
+			pq.Array(&c.Tags),
+			&c.User.Username,
+			&c.CommentCount,
+			&c.LikeCount,
+			&c.Interactions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// same format as scanning the timestamp into the string
+		c.CreatedAt = c.CreatedAtTime.Format(time.RFC3339Nano)
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// GetTagAffinity counts the tags of the posts created between since and
+// until the user created, commented on or liked.
+func (s *PostStore) GetTagAffinity(ctx context.Context, userID int64, since, until time.Time) (map[string]int, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM posts p, unnest(p.tags) AS tag
+		WHERE
+			p.created_at >= $2 AND p.created_at <= $3 AND
+			(
+				p.user_id = $1 OR
+				EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id AND c.user_id = $1) OR
+				EXISTS (SELECT 1 FROM post_likes l WHERE l.post_id = p.id AND l.user_id = $1)
+			)
+		GROUP BY tag
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string]int)
+	for rows.Next() {
+		var (
+			tag   string
+			count int
+		)
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, err
+		}
+		tags[tag] = count
+	}
+
+	return tags, rows.Err()
+}
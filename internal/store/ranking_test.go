@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetRankingCandidates(t *testing.T) {
+	db := newTestDB(t)
+	posts := &PostStore{db}
+	likes := &LikeStore{db}
+	blocks := &BlockStore{db}
+	ctx := context.Background()
+
+	viewer := createTestUser(t, db, "viewer")
+	stranger := createTestUser(t, db, "stranger")
+	muted := createTestUser(t, db, "muted")
+	banned := createTestUser(t, db, "banned")
+	fans := []*User{createTestUser(t, db, "fan1"), createTestUser(t, db, "fan2"), createTestUser(t, db, "fan3")}
+
+	if err := blocks.Mute(ctx, viewer.ID, muted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE users SET banned_at = NOW() WHERE id = $1`, banned.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	asOf := now.Add(-time.Hour)
+
+	// like gives the post n likes at the time
+	like := func(postID int64, n int, at time.Time) {
+		t.Helper()
+		for _, fan := range fans[:n] {
+			if err := likes.Like(ctx, fan.ID, postID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(`UPDATE post_likes SET created_at = $1 WHERE user_id = $2 AND post_id = $3`, at, fan.ID, postID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	quiet := createTestPost(t, db, stranger.ID, asOf.Add(-3*time.Hour))
+	liked := createTestPost(t, db, stranger.ID, asOf.Add(-3*time.Hour))
+	like(liked, 1, asOf.Add(-2*time.Hour))
+	// the most liked posts the viewer may not see don't take the place of
+	// the ones they may
+	like(createTestPost(t, db, muted.ID, asOf.Add(-3*time.Hour)), 3, asOf.Add(-2*time.Hour))
+	like(createTestPost(t, db, banned.ID, asOf.Add(-3*time.Hour)), 3, asOf.Add(-2*time.Hour))
+	// liked after the first page was ranked
+	late := createTestPost(t, db, stranger.ID, asOf.Add(-3*time.Hour))
+	like(late, 3, now)
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []int64
+	}{
+		{name: "most liked visible post", limit: 1, want: []int64{liked}},
+		{name: "then by id", limit: 2, want: []int64{late, liked}},
+		{name: "every visible post", limit: 10, want: []int64{late, liked, quiet}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := posts.GetRankingCandidates(ctx, viewer.ID, asOf.Add(-24*time.Hour), asOf, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]int64, len(candidates))
+			for i, c := range candidates {
+				got[i] = c.ID
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+
+			if !slices.Equal(got, want) {
+				t.Fatalf("candidates = %v, want %v", got, tt.want)
+			}
+
+			for _, c := range candidates {
+				if c.ID == late && c.LikeCount != 0 {
+					t.Fatalf("the likes after the ranking time were counted: %d", c.LikeCount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrTokenReused = errors.New("refresh token has already been used")
+
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	MFA       bool      `json:"mfa"`
+	Expiry    time.Time `json:"expiry"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+// Note: only the hash of the token is stored, same as the user invitations
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token string, rt *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token, rt)
+	})
+}
+
+// Rotate exchanges a valid refresh token for a new one in the same family.
+// Presenting a token that was already rotated means it leaked, so the whole
+// family is revoked, its session ended with the access tokens bound to it,
+// and ErrTokenReused is returned.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error) {
+	var (
+		rotated *RefreshToken
+		reused  bool
+	)
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, usedAt, revokedAt, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if revokedAt.Valid {
+			return ErrNotFound
+		}
+
+		if usedAt.Valid {
+			reused = true
+			if err := s.revokeFamily(ctx, tx, current.FamilyID); err != nil {
+				return err
+			}
+			return s.deleteSession(ctx, tx, current.FamilyID)
+		}
+
+		if current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		if err := s.markUsed(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		rotated = &RefreshToken{
+			UserID:   current.UserID,
+			FamilyID: current.FamilyID,
+			MFA:      current.MFA,
+			Expiry:   time.Now().Add(exp),
+		}
+
+		return s.create(ctx, tx, newToken, rotated)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrTokenReused
+	}
+
+	return rotated, nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.revokeFamily(ctx, tx, familyID)
+	})
+}
+
+// deleteSession ends the session of the family, its id is the family id.
+func (s *RefreshTokenStore) deleteSession(ctx context.Context, tx *sql.Tx, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, familyID)
+	return err
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token string, rt *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, family_id, mfa, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		hashToken(token),
+		rt.UserID,
+		rt.FamilyID,
+		rt.MFA,
+		rt.Expiry,
+	).Scan(
+		&rt.ID,
+		&rt.CreatedAt,
+	)
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, sql.NullTime, sql.NullTime, error) {
+	query := `
+		SELECT id, user_id, family_id, mfa, expiry, created_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var usedAt, revokedAt sql.NullTime
+	rt := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(
+		&rt.ID,
+		&rt.UserID,
+		&rt.FamilyID,
+		&rt.MFA,
+		&rt.Expiry,
+		&rt.CreatedAt,
+		&usedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, usedAt, revokedAt, ErrNotFound
+		default:
+			return nil, usedAt, revokedAt, err
+		}
+	}
+
+	return rt, usedAt, revokedAt, nil
+}
+
+func (s *RefreshTokenStore) markUsed(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	return err
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRotateReuseEndsTheSession(t *testing.T) {
+	db := newTestDB(t)
+	sessions := &SessionStore{db}
+	refreshTokens := &RefreshTokenStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "rotating")
+
+	session := &Session{ID: uuid.NewString(), UserID: user.ID, UserAgent: "test", IP: "127.0.0.1"}
+	rt := &RefreshToken{Expiry: time.Now().Add(time.Hour)}
+	if err := sessions.Create(ctx, session, "first", rt); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := refreshTokens.Rotate(ctx, "first", "second", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := refreshTokens.Rotate(ctx, "first", "third", time.Hour); err != ErrTokenReused {
+		t.Fatalf("rotate a used token: got %v, want ErrTokenReused", err)
+	}
+
+	if _, err := refreshTokens.Rotate(ctx, "second", "fourth", time.Hour); err != ErrNotFound {
+		t.Fatalf("rotate the latest token of the family: got %v, want ErrNotFound", err)
+	}
+
+	if err := sessions.Touch(ctx, user.ID, session.ID); err != ErrNotFound {
+		t.Fatalf("touch the session: got %v, want ErrNotFound", err)
+	}
+}
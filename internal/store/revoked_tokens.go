@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, userID int64, expiry time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expiry) VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, userID, expiry)
+	return err
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpired removes the revoked tokens whose expiry has passed, they are
+// rejected on their expiry anyway.
+func (s *RevokedTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM revoked_tokens WHERE expiry < NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// RevokeAll invalidates every access token issued to the user so far and
+// revokes all of their refresh tokens, sessions and personal access tokens. It
+// returns the new cut-off time, kept to the microsecond like the issue time of
+// the tokens.
+func (s *RevokedTokenStore) RevokeAll(ctx context.Context, userID int64) (time.Time, error) {
+	// Note: the time of the app, the tokens are issued on its clock
+	validAfter := time.Now().Truncate(time.Microsecond)
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return revokeAllTokens(ctx, tx, userID, validAfter)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return validAfter, nil
+}
+
+func revokeAllTokens(ctx context.Context, tx *sql.Tx, userID int64, validAfter time.Time) error {
+	query := `UPDATE users SET tokens_valid_after = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userID, validAfter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	query = `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	// personal access tokens don't carry an issue time to compare to the
+	// cut-off, a compromise has to end them as well
+	_, err = tx.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *RevokedTokenStore) GetValidAfter(ctx context.Context, userID int64) (time.Time, error) {
+	query := `SELECT tokens_valid_after FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var validAfter time.Time
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&validAfter); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return validAfter, ErrNotFound
+		default:
+			return validAfter, err
+		}
+	}
+
+	return validAfter, nil
+}
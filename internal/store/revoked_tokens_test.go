@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevokeAllKeepsMicroseconds(t *testing.T) {
+	db := newTestDB(t)
+	revoked := &RevokedTokenStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "revoking")
+
+	validAfter, err := revoked.RevokeAll(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := revoked.GetValidAfter(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stored.Equal(validAfter) {
+		t.Fatalf("stored cut-off = %v, want %v", stored, validAfter)
+	}
+
+	if _, err := revoked.RevokeAll(ctx, user.ID+1000); err != ErrNotFound {
+		t.Fatalf("revoke the tokens of a missing user: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	db := newTestDB(t)
+	revoked := &RevokedTokenStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "revoked")
+
+	expired, live := uuid.NewString(), uuid.NewString()
+	if err := revoked.Revoke(ctx, expired, user.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := revoked.Revoke(ctx, live, user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := revoked.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d tokens, want 1", deleted)
+	}
+
+	if ok, err := revoked.IsRevoked(ctx, expired); err != nil || ok {
+		t.Fatalf("expired token: revoked %v, err %v, want it gone", ok, err)
+	}
+	if ok, err := revoked.IsRevoked(ctx, live); err != nil || !ok {
+		t.Fatalf("live token: revoked %v, err %v, want it kept", ok, err)
+	}
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is a login on one device. Its ID is the family ID of the refresh
+// tokens issued for it.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+// Create starts the session together with its first refresh token.
+func (s *SessionStore) Create(ctx context.Context, session *Session, token string, rt *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO sessions (id, user_id, user_agent, ip)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at, last_seen_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			session.ID,
+			session.UserID,
+			session.UserAgent,
+			session.IP,
+		).Scan(
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		rt.FamilyID = session.ID
+		rt.UserID = session.UserID
+
+		refreshTokens := &RefreshTokenStore{s.db}
+		return refreshTokens.create(ctx, tx, token, rt)
+	})
+}
+
+// Touch records activity on the session, ErrNotFound means it was ended. The
+// row is only written once per lastUsedResolution, this runs on every request.
+func (s *SessionStore) Touch(ctx context.Context, userID int64, id string) error {
+	query := `
+		SELECT last_seen_at < NOW() - make_interval(secs => $3)
+		FROM sessions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var stale bool
+	if err := s.db.QueryRowContext(ctx, query, id, userID, lastUsedResolution.Seconds()).Scan(&stale); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	if !stale {
+		return nil
+	}
+
+	// Note: concurrent requests only write once
+	query = `
+		UPDATE sessions SET last_seen_at = NOW()
+		WHERE id = $1 AND last_seen_at < NOW() - make_interval(secs => $2)
+	`
+
+	_, err := s.db.ExecContext(ctx, query, id, lastUsedResolution.Seconds())
+	return err
+}
+
+func (s *SessionStore) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Delete ends the session and revokes its refresh tokens.
+func (s *SessionStore) Delete(ctx context.Context, userID int64, id string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, id, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		refreshTokens := &RefreshTokenStore{s.db}
+		return refreshTokens.revokeFamily(ctx, tx, id)
+	})
+}
+
+// DeleteExpired removes the sessions left without a usable refresh token, the
+// device can't come back to them, and the expired refresh tokens. It returns
+// the number of deleted sessions.
+func (s *SessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	var deleted int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM sessions s
+			WHERE NOT EXISTS (
+				SELECT 1 FROM refresh_tokens rt
+				WHERE rt.family_id = s.id AND rt.revoked_at IS NULL AND rt.expiry > NOW()
+			)
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expiry < NOW()`)
+		return err
+	})
+
+	return deleted, err
+}
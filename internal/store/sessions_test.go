@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTouchSession(t *testing.T) {
+	db := newTestDB(t)
+	sessions := &SessionStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "touching")
+
+	session := &Session{ID: uuid.NewString(), UserID: user.ID}
+	if err := sessions.Create(ctx, session, "touch", &RefreshToken{Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	lastSeenAt := func() time.Time {
+		t.Helper()
+		var at time.Time
+		if err := db.QueryRow(`SELECT last_seen_at FROM sessions WHERE id = $1`, session.ID).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+
+	earlier := time.Now().Add(-lastUsedResolution / 2).Truncate(time.Second)
+	if _, err := db.Exec(`UPDATE sessions SET last_seen_at = $2 WHERE id = $1`, session.ID, earlier); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessions.Touch(ctx, user.ID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastSeenAt(); !got.Equal(earlier) {
+		t.Fatalf("touch within the resolution wrote last_seen_at %v, want %v", got, earlier)
+	}
+
+	stale := time.Now().Add(-2 * lastUsedResolution).Truncate(time.Second)
+	if _, err := db.Exec(`UPDATE sessions SET last_seen_at = $2 WHERE id = $1`, session.ID, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessions.Touch(ctx, user.ID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastSeenAt(); !got.After(stale) {
+		t.Fatalf("touch after the resolution left last_seen_at at %v", got)
+	}
+
+	if err := sessions.Touch(ctx, user.ID+1, session.ID); err != ErrNotFound {
+		t.Fatalf("touch the session of another user: got %v, want ErrNotFound", err)
+	}
+	if err := sessions.Touch(ctx, user.ID, uuid.NewString()); err != ErrNotFound {
+		t.Fatalf("touch an unknown session: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	db := newTestDB(t)
+	sessions := &SessionStore{db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db, "expiring")
+
+	live := &Session{ID: uuid.NewString(), UserID: user.ID}
+	if err := sessions.Create(ctx, live, "live", &RefreshToken{Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := &Session{ID: uuid.NewString(), UserID: user.ID}
+	if err := sessions.Create(ctx, expired, "expired", &RefreshToken{Expiry: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := sessions.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d sessions, want 1", deleted)
+	}
+
+	list, err := sessions.GetByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != live.ID {
+		t.Fatalf("sessions = %+v, want the live one only", list)
+	}
+}
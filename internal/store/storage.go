@@ -10,6 +10,7 @@ import (
 var (
 	ErrNotFound          = errors.New("resource not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrThrottled         = errors.New("requested too recently, try again later")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -19,7 +20,13 @@ type Storage struct {
 		Create(context.Context, *Post) error
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
-		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetaData, PageInfo, error)
+		GetTimelinePosts(ctx context.Context, viewerID int64, entries []Cursor) ([]PostWithMetaData, []Cursor, error)
+		GetFanOutOnReadPositions(ctx context.Context, viewerID int64, minFollowers int, after *Cursor, limit int) ([]Cursor, error)
+		GetRankingCandidates(ctx context.Context, viewerID int64, since, until time.Time, limit int) ([]RankingCandidate, error)
+		GetTagAffinity(ctx context.Context, userID int64, since, until time.Time) (map[string]int, error)
+		GetPublic(ctx context.Context, tag string, q CursorQuery) ([]PostWithMetaData, PageInfo, error)
+		GetTrendingTags(ctx context.Context, since, until time.Time, offset, limit int) ([]TrendingTag, error)
 	}
 	Users interface {
 		GetByID(context.Context, int64) (*User, error)
@@ -28,28 +35,149 @@ type Storage struct {
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp, cooldown time.Duration) error
+		ResetPassword(ctx context.Context, token string, newPassword string) (*User, error)
+		GetByIdentity(ctx context.Context, provider, subject string) (*User, error)
+		CreateWithIdentity(context.Context, *User, *UserIdentity) error
+		LinkIdentityByEmail(context.Context, *UserIdentity) error
+		ResendInvitation(ctx context.Context, email, token string, invitationExp, cooldown time.Duration) (*User, error)
+		DeleteOtherInvitations(ctx context.Context, userID int64, token string) error
+		PurgeUnactivated(context.Context, time.Duration) (int64, error)
+		UpdateProfile(ctx context.Context, user *User, usernameHold time.Duration) error
+		CreateEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error
+		ConfirmEmailChange(context.Context, string) (*User, error)
+		GetByPasswordReset(context.Context, string) (*User, error)
+		UpdatePassword(context.Context, *User) error
+		GetByEmailIncludingDeleted(context.Context, string) (*User, error)
+		GetByIDIncludingDeleted(context.Context, int64) (*User, error)
+		SoftDelete(context.Context, int64) error
+		Restore(ctx context.Context, userID int64, window time.Duration) error
+		PurgeDeleted(ctx context.Context, window, usernameHold time.Duration) (int64, []string, error)
+		GetByUsername(context.Context, string) (*User, error)
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
+		List(ctx context.Context, postID, viewerID int64, q CursorQuery) ([]Comment, PageInfo, error)
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
+		Request(ctx context.Context, requesterID, userID int64) error
+		GetRequests(context.Context, int64) ([]FollowRequest, error)
+		Approve(ctx context.Context, userID, requesterID int64) error
+		Reject(ctx context.Context, userID, requesterID int64) error
+		IsFollowing(ctx context.Context, followerID, userID int64) (bool, error)
+		GetFollowers(ctx context.Context, userID, viewerID int64, q CursorQuery) ([]FollowListEntry, PageInfo, error)
+		GetFollowing(ctx context.Context, userID, viewerID int64, q CursorQuery) ([]FollowListEntry, PageInfo, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	LoginAttempts interface {
+		Get(context.Context, string) (*LoginAttempt, error)
+		RecordFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error)
+		Lock(ctx context.Context, key string, until time.Time) error
+		Reset(context.Context, string) error
+		DeleteStale(ctx context.Context, before time.Time) (int64, error)
+	}
+	RefreshTokens interface {
+		Create(ctx context.Context, token string, rt *RefreshToken) error
+		Rotate(ctx context.Context, token, newToken string, exp time.Duration) (*RefreshToken, error)
+		RevokeFamily(context.Context, string) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, userID int64, expiry time.Time) error
+		IsRevoked(context.Context, string) (bool, error)
+		RevokeAll(context.Context, int64) (time.Time, error)
+		GetValidAfter(context.Context, int64) (time.Time, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	MFA interface {
+		Get(context.Context, int64) (*MFA, error)
+		CreatePending(ctx context.Context, userID int64, secret string) error
+		Enable(ctx context.Context, userID int64, step int64, recoveryCodes []string) error
+		Disable(context.Context, int64) error
+		UseStep(ctx context.Context, userID int64, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, codes []string) error
+	}
+	OIDCStates interface {
+		Create(ctx context.Context, state string, oidcState *OIDCState) error
+		Consume(context.Context, string) (*OIDCState, error)
+	}
+	Sessions interface {
+		Create(ctx context.Context, session *Session, token string, rt *RefreshToken) error
+		Touch(ctx context.Context, userID int64, id string) error
+		GetByUserID(context.Context, int64) ([]Session, error)
+		Delete(ctx context.Context, userID int64, id string) error
+		DeleteExpired(context.Context) (int64, error)
+	}
+	DataExports interface {
+		Create(ctx context.Context, token string, export *DataExport, cooldown time.Duration) error
+		Complete(ctx context.Context, id int64, filePath string, expiry time.Time) error
+		Fail(context.Context, int64) error
+		FailStale(ctx context.Context, before time.Time) (int64, error)
+		GetByToken(context.Context, string) (*DataExport, error)
+		DeleteExpired(context.Context) ([]string, error)
+		CollectPersonalData(context.Context, int64) (*PersonalData, error)
+	}
+	Moderation interface {
+		Suspend(context.Context, *ModerationAction) error
+		Ban(context.Context, *ModerationAction) error
+		Lift(context.Context, *ModerationAction) error
+		Appeal(context.Context, *ModerationAction) error
+		GetByUserID(context.Context, int64) ([]ModerationAction, error)
+	}
+	AccessTokens interface {
+		Create(ctx context.Context, token string, pat *PersonalAccessToken) error
+		GetByToken(context.Context, string) (*PersonalAccessToken, error)
+		GetByUserID(context.Context, int64) ([]PersonalAccessToken, error)
+		Delete(ctx context.Context, userID, id int64) error
+	}
+	Timelines interface {
+		FanOut(ctx context.Context, postID int64) ([]int64, Cursor, error)
+		Backfill(ctx context.Context, userID, authorID int64, since time.Time) error
+		RemoveAuthor(ctx context.Context, userID, authorID int64) error
+		RemovePost(ctx context.Context, postID int64) ([]int64, Cursor, error)
+		Get(ctx context.Context, userID int64, after *Cursor, limit int) ([]Cursor, error)
+		DeleteOlderThan(context.Context, time.Time) (int64, error)
+	}
+	Likes interface {
+		Like(ctx context.Context, userID, postID int64) error
+		Unlike(ctx context.Context, userID, postID int64) error
+	}
+	Blocks interface {
+		Block(ctx context.Context, userID, blockedID int64) error
+		Unblock(ctx context.Context, userID, blockedID int64) error
+		GetBlocked(context.Context, int64) ([]RelatedUser, error)
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+		Mute(ctx context.Context, userID, mutedID int64) error
+		Unmute(ctx context.Context, userID, mutedID int64) error
+		GetMuted(context.Context, int64) ([]RelatedUser, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
 		// initializing the stores
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Posts:         &PostStore{db},
+		Users:         &UserStore{db},
+		Comments:      &CommentStore{db},
+		Followers:     &FollowerStore{db},
+		Roles:         &RoleStore{db},
+		LoginAttempts: &LoginAttemptStore{db},
+		RefreshTokens: &RefreshTokenStore{db},
+		RevokedTokens: &RevokedTokenStore{db},
+		MFA:           &MFAStore{db},
+		OIDCStates:    &OIDCStateStore{db},
+		Sessions:      &SessionStore{db},
+		AccessTokens:  &PersonalAccessTokenStore{db},
+		DataExports:   &DataExportStore{db},
+		Moderation:    &ModerationStore{db},
+		Blocks:        &BlockStore{db},
+		Timelines:     &TimelineStore{db},
+		Likes:         &LikeStore{db},
 	}
 }
 
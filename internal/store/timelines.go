@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TimelineStore holds the materialised home timelines: the posts are pushed
+// to the timelines of the followers of their author when created, so the
+// feed doesn't join the followers on every read. Timelines are the
+// postgres fallback of the timelines cached in redis.
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// FanOut pushes the post to the timelines of its author and their followers,
+// except for the followers who muted the author. It returns the users whose timeline received it and the post position.
+func (s *TimelineStore) FanOut(ctx context.Context, postID int64) ([]int64, Cursor, error) {
+	query := `
+		INSERT INTO timeline_entries (user_id, post_id, author_id, created_at)
+		SELECT f.follower_id, p.id, p.user_id, p.created_at
+		FROM posts p
+		JOIN followers f ON f.user_id = p.user_id
+		WHERE p.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM user_mutes m WHERE m.user_id = f.follower_id AND m.muted_id = p.user_id
+		)
+		UNION ALL
+		SELECT p.user_id, p.id, p.user_id, p.created_at
+		FROM posts p
+		WHERE p.id = $1
+		ON CONFLICT DO NOTHING
+		RETURNING user_id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, Cursor{}, err
+	}
+	defer rows.Close()
+
+	position := Cursor{ID: postID}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID, &position.CreatedAt); err != nil {
+			return nil, Cursor{}, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, position, rows.Err()
+}
+
+// Backfill pushes the posts the author created since then to the timeline
+// of the user who just followed or unmuted them.
+func (s *TimelineStore) Backfill(ctx context.Context, userID, authorID int64, since time.Time) error {
+	query := `
+		INSERT INTO timeline_entries (user_id, post_id, author_id, created_at)
+		SELECT $1, p.id, p.user_id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $2 AND p.created_at >= $3 AND NOT EXISTS (
+			SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = $2
+		)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, authorID, since)
+	return err
+}
+
+// RemoveAuthor takes the posts of the author out of the timeline of the user.
+func (s *TimelineStore) RemoveAuthor(ctx context.Context, userID, authorID int64) error {
+	query := `DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, authorID)
+	return err
+}
+
+// RemovePost takes the post out of every timeline and returns their users.
+// It must run before the post is deleted, the cascade would remove the
+// entries without telling whose timelines they were in.
+func (s *TimelineStore) RemovePost(ctx context.Context, postID int64) ([]int64, Cursor, error) {
+	query := `DELETE FROM timeline_entries WHERE post_id = $1 RETURNING user_id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, Cursor{}, err
+	}
+	defer rows.Close()
+
+	position := Cursor{ID: postID}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID, &position.CreatedAt); err != nil {
+			return nil, Cursor{}, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, position, rows.Err()
+}
+
+// Get returns up to limit positions of the timeline of the user following
+// after, newest first.
+func (s *TimelineStore) Get(ctx context.Context, userID int64, after *Cursor, limit int) ([]Cursor, error) {
+	cond, _, keysetArgs := CursorQuery{Cursor: after}.keyset(true, "created_at", "post_id", 3)
+
+	query := `
+		SELECT post_id, created_at
+		FROM timeline_entries
+		WHERE user_id = $1 AND ` + cond + `
+		ORDER BY created_at DESC, post_id DESC
+		LIMIT $2
+	`
+
+	args := append([]any{userID, limit}, keysetArgs...)
+
+	return s.positions(ctx, query, args...)
+}
+
+// DeleteOlderThan trims the timelines, older posts are read with the join
+// over the followers.
+func (s *TimelineStore) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM timeline_entries WHERE created_at < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *TimelineStore) positions(ctx context.Context, query string, args ...any) ([]Cursor, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []Cursor
+	for rows.Next() {
+		var c Cursor
+		if err := rows.Scan(&c.ID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		positions = append(positions, c)
+	}
+
+	return positions, rows.Err()
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetByUsername returns the user currently using the username or, while the
+// redirect lasts, the user who used it before renaming.
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT id FROM users WHERE username = $1
+		UNION ALL
+		(
+			SELECT user_id FROM username_history
+			WHERE username = $1 AND redirect_until > NOW() AND user_id IS NOT NULL
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := s.db.QueryRowContext(ctx, query, username).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return s.GetByID(ctx, userID)
+}
+
+// checkUsernameHeld returns ErrDuplicateUsername when the username is still
+// held for another user after a rename or a deletion.
+func (s *UserStore) checkUsernameHeld(ctx context.Context, tx *sql.Tx, username string, userID int64) error {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM username_history
+			WHERE username = $1 AND redirect_until > NOW() AND user_id IS DISTINCT FROM $2
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var held bool
+	if err := tx.QueryRowContext(ctx, query, username, userID).Scan(&held); err != nil {
+		return err
+	}
+
+	if held {
+		return ErrDuplicateUsername
+	}
+
+	return nil
+}
+
+// holdUsernames records the current usernames of the users so they redirect
+// to them, and nobody else can take them, for the hold duration.
+func (s *UserStore) holdUsernames(ctx context.Context, tx *sql.Tx, userIDs []int64, hold time.Duration) error {
+	query := `
+		INSERT INTO username_history (user_id, username, redirect_until)
+		SELECT id, username, $2 FROM users WHERE id = ANY($1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(userIDs), time.Now().Add(hold))
+	return err
+}
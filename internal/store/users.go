@@ -8,23 +8,45 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrEditConflict      = errors.New("the user was modified by another request, reload it and try again")
 )
 
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  password `json:"-"` // not returning password on marshal/unmarshal user
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleID    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	ID          int64    `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Password    password `json:"-"` // not returning password on marshal/unmarshal user
+	CreatedAt   string   `json:"created_at"`
+	IsActive    bool     `json:"is_active"`
+	RoleID      int64    `json:"role_id"`
+	Role        Role     `json:"role"`
+	DisplayName string   `json:"display_name"`
+	Bio         string   `json:"bio"`
+	AvatarURL   string   `json:"avatar_url"`
+	Website     string   `json:"website"`
+	Location    string   `json:"location"`
+	Version     int      `json:"version"`
+	// IsPrivate users approve their followers, only they see their posts
+	IsPrivate bool `json:"is_private"`
+	// the counters are kept up to date by triggers, they include the follows
+	// and posts of deleted and banned users until those are purged
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+	PostsCount     int `json:"posts_count"`
+	// DeletedAt is only loaded by the lookups that include deleted users
+	DeletedAt *time.Time `json:"-"`
+	// the moderation state is checked on every request, it is only shown to
+	// moderators by the moderation history
+	SuspendedUntil   *time.Time `json:"-"`
+	BannedAt         *time.Time `json:"-"`
+	ModerationReason string     `json:"-"`
 }
 
 type password struct {
@@ -44,6 +66,10 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+func (p *password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+}
+
 type UserStore struct {
 	db *sql.DB
 }
@@ -68,6 +94,10 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		role = "user"
 	}
 
+	if err := s.checkUsernameHeld(ctx, tx, user.Username, 0); err != nil {
+		return err
+	}
+
 	err := tx.QueryRowContext(
 		ctx,
 		query,
@@ -80,41 +110,80 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		&user.CreatedAt,
 	)
 	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
-			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
-			return ErrDuplicateUsername
-		default:
-			return err
-		}
+		return userConstraintError(err)
 	}
 
 	return nil
 }
 
+// userConstraintError maps unique violations on users to their errors.
+func userConstraintError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != "23505" {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case "users_email_key":
+		return ErrDuplicateEmail
+	case "users_username_key":
+		return ErrDuplicateUsername
+	default:
+		return ErrConflict
+	}
+}
+
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
+	return s.getByID(ctx, userID, false)
+}
+
+// GetByIDIncludingDeleted also finds soft deleted users, for the logins that
+// finish after the password was checked.
+func (s *UserStore) GetByIDIncludingDeleted(ctx context.Context, userID int64) (*User, error) {
+	return s.getByID(ctx, userID, true)
+}
+
+func (s *UserStore) getByID(ctx context.Context, userID int64, includeDeleted bool) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at, roles.*
+		SELECT users.id, username, email, password, created_at,
+			display_name, bio, avatar_url, website, location, version, is_private,
+			followers_count, following_count, posts_count, deleted_at,
+			suspended_until, banned_at, moderation_reason, roles.*
 		FROM users
 		JOIN roles ON (users.role_id = roles.id)
-		WHERE users.id = $1 AND is_active = true
+		WHERE users.id = $1 AND is_active = true AND (deleted_at IS NULL OR $2)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	var deletedAt, suspendedUntil, bannedAt sql.NullTime
 	user := &User{}
 	err := s.db.QueryRowContext(
 		ctx,
 		query,
 		userID,
+		includeDeleted,
 	).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.Website,
+		&user.Location,
+		&user.Version,
+		&user.IsPrivate,
+		&user.FollowersCount,
+		&user.FollowingCount,
+		&user.PostsCount,
+		&deletedAt,
+		&suspendedUntil,
+		&bannedAt,
+		&user.ModerationReason,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -129,6 +198,16 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		}
 	}
 
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+
 	return user, nil
 }
 
@@ -231,6 +310,90 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
 }
 
+// UpdateProfile saves the editable profile fields. The update only applies to
+// the version of the user that was read, ErrEditConflict means it changed since.
+// A renamed user keeps their old username, which redirects to them, for
+// usernameHold.
+func (s *UserStore) UpdateProfile(ctx context.Context, user *User, usernameHold time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var current string
+		if err := tx.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&current); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if current != user.Username {
+			if err := s.checkUsernameHeld(ctx, tx, user.Username, user.ID); err != nil {
+				return err
+			}
+
+			// taking back one of your own old usernames ends its redirect
+			if _, err := tx.ExecContext(ctx, `DELETE FROM username_history WHERE user_id = $1 AND username = $2`, user.ID, user.Username); err != nil {
+				return err
+			}
+
+			if err := s.holdUsernames(ctx, tx, []int64{user.ID}, usernameHold); err != nil {
+				return err
+			}
+		}
+
+		query := `
+			UPDATE users
+			SET username = $1, display_name = $2, bio = $3, avatar_url = $4, website = $5, location = $6,
+				is_private = $7, version = version + 1
+			WHERE id = $8 AND version = $9
+			RETURNING version
+		`
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			user.Username,
+			user.DisplayName,
+			user.Bio,
+			user.AvatarURL,
+			user.Website,
+			user.Location,
+			user.IsPrivate,
+			user.ID,
+			user.Version,
+		).Scan(&user.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return userConstraintError(err)
+			}
+		}
+
+		if user.IsPrivate {
+			return nil
+		}
+
+		// a public account has nothing to approve anymore
+		query = `
+			WITH requests AS (
+				DELETE FROM follow_requests WHERE user_id = $1
+				RETURNING user_id, requester_id
+			)
+			INSERT INTO followers (user_id, follower_id)
+			SELECT user_id, requester_id FROM requests
+			ON CONFLICT DO NOTHING
+		`
+
+		_, err = tx.ExecContext(ctx, query, user.ID)
+		return err
+	})
+}
+
 func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM user_invitations WHERE user_id = $1`
 
@@ -273,22 +436,186 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 	return nil
 }
 
+// CreatePasswordReset adds a reset to the user. A new reset is refused with
+// ErrThrottled while the last one is younger than cooldown.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp, cooldown time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// serialize the requests of the user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+
+		var lastSent sql.NullTime
+		query := `SELECT MAX(created_at) FROM password_resets WHERE user_id = $1`
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&lastSent); err != nil {
+			return err
+		}
+		if lastSent.Valid && time.Since(lastSent.Time) < cooldown {
+			return ErrThrottled
+		}
+
+		query = `INSERT INTO password_resets (token, user_id, expiry) VALUES ($1, $2, $3)`
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ResetPassword sets the new password of the user the reset token belongs to.
+// The token is single use, every pending reset of the user is removed.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, newPassword string) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// 1. find the user that this token belongs to
+		u, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		// 2. update the password
+		if err := u.Password.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, u); err != nil {
+			return err
+		}
+
+		// 3. clean the resets
+		if err := s.deletePasswordResets(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
+		user = u
+		return nil
+	})
+
+	return user, err
+}
+
+// GetByPasswordReset returns the user a valid reset token was issued for.
+func (s *UserStore) GetByPasswordReset(ctx context.Context, token string) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = s.getUserFromPasswordReset(ctx, tx, token)
+		return err
+	})
+
+	return user, err
+}
+
+// UpdatePassword saves the password set on the user. Pending reset links are
+// no longer needed.
+func (s *UserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.deletePasswordResets(ctx, tx, user.ID)
+	})
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return s.getByEmail(ctx, email, false)
+}
+
+// GetByEmailIncludingDeleted also finds soft deleted users, logging in is how
+// they restore their account.
+func (s *UserStore) GetByEmailIncludingDeleted(ctx context.Context, email string) (*User, error) {
+	return s.getByEmail(ctx, email, true)
+}
+
+func (s *UserStore) getByEmail(ctx context.Context, email string, includeDeleted bool) (*User, error) {
 	query := `
-		SELECT id, username, email, password, created_at FROM users
-		WHERE email = $1 AND is_active = true
+		SELECT id, username, email, password, created_at, deleted_at,
+			suspended_until, banned_at, moderation_reason
+		FROM users
+		WHERE email = $1 AND is_active = true AND (deleted_at IS NULL OR $2)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	var deletedAt, suspendedUntil, bannedAt sql.NullTime
 	user := &User{}
-	err := s.db.QueryRowContext(ctx, query, email).Scan(
+	err := s.db.QueryRowContext(ctx, query, email, includeDeleted).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&deletedAt,
+		&suspendedUntil,
+		&bannedAt,
+		&user.ModerationReason,
 	)
 	if err != nil {
 		switch err {
@@ -299,5 +626,15 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		}
 	}
 
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+
 	return user, nil
 }